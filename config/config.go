@@ -8,6 +8,16 @@ type DbConfig struct {
 	Password     string `env:"RDS_PASSWORD"`
 	MaxOpenConns string `env:"MAX_OPEN_CONNS" default:"25"`
 	MaxIdleConns string `env:"MAX_IDLE_CONNS" default:"5"`
+
+	MigrationLockTimeout       string `env:"MIGRATION_LOCK_TIMEOUT" default:"60s"`
+	MigrationDryRun            string `env:"MIGRATION_DRY_RUN" default:"false"`
+	MigrationLint              string `env:"MIGRATION_LINT" default:"warn"`
+	MigrationDeprecationWindow string `env:"MIGRATION_DEPRECATION_WINDOW" default:"168h"`
+
+	// Queries slower than the threshold are logged, 0 disables the log
+	SlowQueryThreshold string `env:"SLOW_QUERY_THRESHOLD" default:"500ms"`
+	// Share of slow queries whose plan is captured with EXPLAIN, 0 to 1
+	SlowQueryExplainRate string `env:"SLOW_QUERY_EXPLAIN_RATE" default:"0"`
 }
 
 type AppConfig struct {
@@ -16,6 +26,45 @@ type AppConfig struct {
 	Env  string `env:"APP_ENV" default:"development"`
 	Host string `env:"APP_HOST" default:"localhost"`
 }
+type LogConfig struct {
+	Level string `env:"LOG_LEVEL" default:"info"`
+	// Overrides per component or route, e.g. `repositories=debug,/rooms/:id=trace`
+	Levels string `env:"LOG_LEVELS" default:""`
+}
+
+type TracingConfig struct {
+	// Exporter is one of none, otlp or stdout
+	Exporter string `env:"TRACING_EXPORTER" default:"none"`
+	// Endpoint is the host:port of the OTLP HTTP collector
+	Endpoint    string `env:"TRACING_OTLP_ENDPOINT" default:"localhost:4318"`
+	Insecure    string `env:"TRACING_OTLP_INSECURE" default:"false"`
+	SampleRatio string `env:"TRACING_SAMPLE_RATIO" default:"1"`
+}
+
+type DebugConfig struct {
+	PprofEnabled string `env:"PPROF_ENABLED" default:"false"`
+	// Permission needed to access /sys/debug/pprof
+	PprofPermission string `env:"PPROF_PERMISSION" default:"debug:pprof"`
+	// Permission needed to access /sys/info
+	InfoPermission string `env:"INFO_PERMISSION" default:"debug:info"`
+	// Permission needed to access /sys/db
+	DbPermission string `env:"DB_PERMISSION" default:"debug:db"`
+}
+
+type CorsConfig struct {
+	// Comma separated origins allowed in every environment, exact or with a
+	// subdomain wildcard, e.g. `https://app.example.com,https://*.example.com`
+	AllowedOrigins string `env:"CORS_ALLOWED_ORIGINS" default:"*"`
+	// Origins added in one environment, matched against APP_ENV, e.g.
+	// `development=http://localhost:3000|http://localhost:5173,staging=https://*.staging.example.com`
+	AllowedOriginsByEnv string `env:"CORS_ALLOWED_ORIGINS_BY_ENV" default:""`
+	AllowCredentials    string `env:"CORS_ALLOW_CREDENTIALS" default:"false"`
+	// Comma separated, empty keeps the defaults
+	AllowedHeaders string `env:"CORS_ALLOWED_HEADERS" default:""`
+	ExposedHeaders string `env:"CORS_EXPOSED_HEADERS" default:""`
+	MaxAge         string `env:"CORS_MAX_AGE" default:"12h"`
+}
+
 type AuthConfig struct {
 	Auth0Url string `env:"AUTH0_URL"`
 }
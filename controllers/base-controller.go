@@ -3,26 +3,43 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/events"
 	"github.com/ginerator/base/model/query"
+	"github.com/ginerator/base/redact"
+	"github.com/ginerator/base/utils"
 	"github.com/ginerator/base/validators"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
-	"github.com/rs/zerolog/log"
 
 	"github.com/gin-gonic/gin"
 	"github.com/samber/lo"
 )
 
-func formatValidationErrors(errs error) *errors.CustomError {
+const (
+	logComponent            = "controllers"
+	streamHeartbeatInterval = 15 * time.Second
+)
+
+// formatValidationErrors describes the first validation error of s. Values of
+// redacted fields aren't echoed.
+func formatValidationErrors(errs error, s interface{}) *errors.CustomError {
 	if _, ok := errs.(*validator.InvalidValidationError); ok {
 		return errors.NewInvalidPayloadError("INVALID_PAYLOAD", errs)
 	}
 
 	err := errs.(validator.ValidationErrors)[0]
+	if redact.IsRedacted(s, err.StructNamespace()) {
+		return errors.NewInvalidPayloadError("INVALID_PAYLOAD", fmt.Errorf("Value for attribute '%s' is not of type: %s", err.Field(), err.Tag()))
+	}
 
 	return errors.NewInvalidPayloadError("INVALID_PAYLOAD", fmt.Errorf("Value '%s' for attribute '%s' is not of type: %s", err.Value(), err.Field(), err.Tag()))
 }
@@ -34,22 +51,22 @@ func Create[R interface{}, M interface{}](ctx *gin.Context, validator *validator
 	decoder.DisallowUnknownFields()
 
 	if err := decoder.Decode(&request); err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - Create - Error decoding request")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - Create - Error decoding request")
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	validationErrors := validator.Struct(request)
 	if validationErrors != nil {
-		log.Error().Err(validationErrors).Msg("[BASE CONTROLLER] - Create - Error validating struct")
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": formatValidationErrors(validationErrors).Error()})
+		logger.For(ctx, logComponent).Error().Err(validationErrors).Msg("[BASE CONTROLLER] - Create - Error validating struct")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": formatValidationErrors(validationErrors, request).Error()})
 		return
 	}
 
 	entity, err := serviceFunction(ctx, request)
 	if err != nil {
-		log.Error().Err(validationErrors).Msg("[BASE CONTROLLER] - Create - Error in service function")
-		ctx.JSON(400, err)
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - Create - Error in service function")
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusCreated, gin.H{"data": entity})
@@ -68,22 +85,22 @@ func CreateWithExternalId[R interface{}, M interface{}](ctx *gin.Context, valida
 	decoder.DisallowUnknownFields()
 
 	if err := decoder.Decode(&request); err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - CreateWithExternalId - Error decoding request")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - CreateWithExternalId - Error decoding request")
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	validationErrors := validator.Struct(request)
 	if validationErrors != nil {
-		log.Error().Err(validationErrors).Msg("[BASE CONTROLLER] - CreateWithExternalId - Error validating struct")
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": formatValidationErrors(validationErrors).Error()})
+		logger.For(ctx, logComponent).Error().Err(validationErrors).Msg("[BASE CONTROLLER] - CreateWithExternalId - Error validating struct")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": formatValidationErrors(validationErrors, request).Error()})
 		return
 	}
 
 	entity, err := serviceFunction(ctx, externalId, request)
 	if err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - CreateWithExternalId - Error in service function")
-		ctx.JSON(400, err)
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - CreateWithExternalId - Error in service function")
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusCreated, gin.H{"data": entity})
@@ -99,14 +116,14 @@ func validateQuery[Q interface{}](ctx *gin.Context, allowedQueryParams []string)
 	var query Q
 	if len(unknowFields) > 0 {
 		err := fmt.Errorf("The following field(s) are not allowed: %s. Allowed fields are: %s", strings.Join(unknowFields, ", "), strings.Join(allowedQueryParams, ", "))
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - validateQuery - Unknown fields")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - validateQuery - Unknown fields")
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return query, err
 	}
 
 	// Parse query
 	if err := ctx.ShouldBindQuery(&query); err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - validateQuery - Does not bind query")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - validateQuery - Does not bind query")
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return query, err
 	}
@@ -114,17 +131,33 @@ func validateQuery[Q interface{}](ctx *gin.Context, allowedQueryParams []string)
 	return query, nil
 }
 
+// bindAsOf stores the optional `asOf` query param in the context, where
+// versioned repositories read it from.
+func bindAsOf(ctx *gin.Context) error {
+	rawAsOf, exists := ctx.GetQuery(utils.AsOfTag)
+	if !exists {
+		return nil
+	}
+
+	asOf, err := time.Parse(time.RFC3339, rawAsOf)
+	if err != nil {
+		return errors.NewInvalidPayloadError("INVALID_QUERY", fmt.Errorf("Value '%s' for attribute '%s' is not a RFC 3339 date", rawAsOf, utils.AsOfTag))
+	}
+	ctx.Set(utils.AsOfTag, asOf)
+	return nil
+}
+
 func GetOne[M interface{}](ctx *gin.Context, serviceFunction func(*gin.Context, uuid.UUID) (M, error)) {
 	uuid, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - GetOne - Retrieving id")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetOne - Retrieving id")
 		ctx.Error(err)
 		return
 	}
 
 	entity, err := serviceFunction(ctx, uuid)
 	if err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - GetOne - Calling service function")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetOne - Calling service function")
 		ctx.Error(err)
 		return
 	}
@@ -134,14 +167,14 @@ func GetOne[M interface{}](ctx *gin.Context, serviceFunction func(*gin.Context,
 func GetOneHidrated[Q interface{}, M interface{}, A interface{}](ctx *gin.Context, allowedQueryParams []string, queryParser func(Q) (A, error), serviceFunction func(*gin.Context, uuid.UUID, A) (M, error)) {
 	uuid, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - GetOneHydrated - Retrieving id")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetOneHydrated - Retrieving id")
 		ctx.Error(err)
 		return
 	}
 
 	query, err := validateQuery[Q](ctx, allowedQueryParams)
 	if err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - GetOneHydrated - Validating query")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetOneHydrated - Validating query")
 		ctx.Error(err)
 		return
 	}
@@ -157,7 +190,7 @@ func GetOneHidrated[Q interface{}, M interface{}, A interface{}](ctx *gin.Contex
 
 	entity, err := serviceFunction(ctx, uuid, additionalQueryData)
 	if err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - GetOneHydrated - Calling service function")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetOneHydrated - Calling service function")
 		ctx.Error(err)
 		return
 	}
@@ -167,14 +200,20 @@ func GetOneHidrated[Q interface{}, M interface{}, A interface{}](ctx *gin.Contex
 func GetMany[Q interface{}, M interface{}](ctx *gin.Context, validator *validator.Validate, serviceFunction func(*gin.Context, Q) ([]M, query.ResponseMeta, error)) {
 	var query Q
 	if err := ctx.ShouldBindQuery(&query); err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - GetMany - Does not bind query")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetMany - Does not bind query")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := bindAsOf(ctx); err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetMany - Invalid asOf")
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	isValidQuery, unknownFields := validators.IsValidQuery(ctx, query)
+	isValidQuery, unknownFields := validators.IsValidQuery(ctx, query, utils.AsOfTag)
 	if !isValidQuery {
-		log.Error().Str("unknownFields", strings.Join(unknownFields, ", ")).Msg("[BASE CONTROLLER] - GetMany - Invalid query")
+		logger.For(ctx, logComponent).Error().Str("unknownFields", strings.Join(unknownFields, ", ")).Msg("[BASE CONTROLLER] - GetMany - Invalid query")
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query params"})
 		return
 
@@ -182,14 +221,14 @@ func GetMany[Q interface{}, M interface{}](ctx *gin.Context, validator *validato
 
 	validationErrors := validator.Struct(query)
 	if validationErrors != nil {
-		log.Error().Err(validationErrors).Msg("[BASE CONTROLLER] - GetMany - Validating struct")
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": formatValidationErrors(validationErrors).Error()})
+		logger.For(ctx, logComponent).Error().Err(validationErrors).Msg("[BASE CONTROLLER] - GetMany - Validating struct")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": formatValidationErrors(validationErrors, query).Error()})
 		return
 	}
 
 	entitys, responseMeta, err := serviceFunction(ctx, query)
 	if err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - GetMany - Calling service function")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetMany - Calling service function")
 		ctx.Error(err)
 		return
 	}
@@ -203,21 +242,27 @@ func GetMany[Q interface{}, M interface{}](ctx *gin.Context, validator *validato
 func GetManyWithExternalId[Q interface{}, M interface{}](ctx *gin.Context, validator *validator.Validate, serviceFunction func(*gin.Context, uuid.UUID, Q) ([]M, query.ResponseMeta, error)) {
 	externalId, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - GetManyWithExternalId - Retrieving id")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetManyWithExternalId - Retrieving id")
 		ctx.Error(err)
 		return
 	}
 
 	var query Q
 	if err := ctx.ShouldBindQuery(&query); err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - GetManyWithExternalId - Does not bind query")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetManyWithExternalId - Does not bind query")
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	isValidQuery, unknownFields := validators.IsValidQuery(ctx, query)
+	if err := bindAsOf(ctx); err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetManyWithExternalId - Invalid asOf")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	isValidQuery, unknownFields := validators.IsValidQuery(ctx, query, utils.AsOfTag)
 	if !isValidQuery {
-		log.Error().Str("unknownFields", strings.Join(unknownFields, ", ")).Msg("[BASE CONTROLLER] - GetManyWithExternalId - Invalid query params")
+		logger.For(ctx, logComponent).Error().Str("unknownFields", strings.Join(unknownFields, ", ")).Msg("[BASE CONTROLLER] - GetManyWithExternalId - Invalid query params")
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query params"})
 		return
 
@@ -225,14 +270,14 @@ func GetManyWithExternalId[Q interface{}, M interface{}](ctx *gin.Context, valid
 
 	validationErrors := validator.Struct(query)
 	if validationErrors != nil {
-		log.Error().Err(validationErrors).Msg("[BASE CONTROLLER] - GetManyWithExternalId - Validating struct")
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": formatValidationErrors(validationErrors).Error()})
+		logger.For(ctx, logComponent).Error().Err(validationErrors).Msg("[BASE CONTROLLER] - GetManyWithExternalId - Validating struct")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": formatValidationErrors(validationErrors, query).Error()})
 		return
 	}
 
 	entities, responseMeta, err := serviceFunction(ctx, externalId, query)
 	if err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - GetManyWithExternalId - Calling service function")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetManyWithExternalId - Calling service function")
 		ctx.Error(err)
 		return
 	}
@@ -246,7 +291,7 @@ func GetManyWithExternalId[Q interface{}, M interface{}](ctx *gin.Context, valid
 func UpdateOne[R interface{}, M interface{}](ctx *gin.Context, validator *validator.Validate, serviceFunction func(*gin.Context, uuid.UUID, R) (M, error)) {
 	uuid, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - UpdateOne - Retrieving id")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - UpdateOne - Retrieving id")
 		ctx.Error(err)
 		return
 	}
@@ -256,23 +301,123 @@ func UpdateOne[R interface{}, M interface{}](ctx *gin.Context, validator *valida
 	decoder.DisallowUnknownFields()
 
 	if err := decoder.Decode(&request); err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - UpdateOne - Decoding struct")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - UpdateOne - Decoding struct")
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	validationErrors := validator.Struct(request)
 	if validationErrors != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - UpdateOne - Validating struct")
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": formatValidationErrors(validationErrors).Error()})
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - UpdateOne - Validating struct")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": formatValidationErrors(validationErrors, request).Error()})
 		return
 	}
 
 	entityUpdated, err := serviceFunction(ctx, uuid, request)
 	if err != nil {
-		log.Error().Err(err).Msg("[BASE CONTROLLER] - UpdateOne - Calling service function")
-		ctx.JSON(400, err)
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - UpdateOne - Calling service function")
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"data": entityUpdated})
 }
+
+func DeleteOne[M interface{}](ctx *gin.Context, serviceFunction func(*gin.Context, uuid.UUID) (M, error)) {
+	uuid, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - DeleteOne - Retrieving id")
+		ctx.Error(err)
+		return
+	}
+
+	entityDeleted, err := serviceFunction(ctx, uuid)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - DeleteOne - Calling service function")
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": entityDeleted})
+}
+
+func GetHistory[E interface{}](ctx *gin.Context, serviceFunction func(*gin.Context, uuid.UUID) ([]E, query.ResponseMeta, error)) {
+	uuid, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetHistory - Retrieving id")
+		ctx.Error(err)
+		return
+	}
+
+	entries, responseMeta, err := serviceFunction(ctx, uuid)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - GetHistory - Calling service function")
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"meta": responseMeta,
+		"data": entries,
+	})
+}
+
+// Stream pushes the change events of M to the client over Server-Sent Events
+// until it disconnects. Clients reconnecting with `Last-Event-ID` get the
+// events they missed first.
+func Stream[M interface{}](ctx *gin.Context, serviceFunction func(*gin.Context, int64) (<-chan events.ChangeEvent, func(), error)) {
+	var lastEventId int64
+	if rawLastEventId := ctx.GetHeader("Last-Event-ID"); rawLastEventId != "" {
+		parsedLastEventId, err := strconv.ParseInt(rawLastEventId, 10, 64)
+		if err != nil {
+			logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - Stream - Invalid Last-Event-ID")
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Value '%s' for header 'Last-Event-ID' is not an event id", rawLastEventId)})
+			return
+		}
+		lastEventId = parsedLastEventId
+	}
+
+	changeEvents, unsubscribe, err := serviceFunction(ctx, lastEventId)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[BASE CONTROLLER] - Stream - Calling service function")
+		ctx.Error(err)
+		return
+	}
+	defer unsubscribe()
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			// Comments keep proxies from closing idle connections
+			_, err := w.Write([]byte(": heartbeat\n\n"))
+			return err == nil
+		case changeEvent, open := <-changeEvents:
+			if !open {
+				return false
+			}
+
+			var entity M
+			if err := json.Unmarshal(changeEvent.Payload, &entity); err != nil {
+				logger.For(ctx, logComponent).Error().Err(err).Int64("id", changeEvent.Id).Msg("[BASE CONTROLLER] - Stream - Decoding change event")
+				return true
+			}
+			ctx.Render(-1, sse.Event{
+				Id:    strconv.FormatInt(changeEvent.Id, 10),
+				Event: strings.ToLower(changeEvent.Action),
+				Data: gin.H{
+					"entityId":  changeEvent.EntityId,
+					"createdAt": changeEvent.CreatedAt,
+					"data":      entity,
+				},
+			})
+			return true
+		}
+	})
+}
@@ -9,6 +9,7 @@ type CustomError struct {
 	Code        string `json:"code"`
 	Message     string `json:"message"`
 	IsRetryable bool   `json:"-"`
+	RequestId   string `json:"requestId,omitempty"`
 }
 
 func (a *CustomError) Error() string {
@@ -23,6 +24,13 @@ func (customError *CustomError) NotRetryable() {
 	customError.IsRetryable = false
 }
 
+// WithRequestId returns a copy of the error carrying the id of the request it answers.
+func (customError *CustomError) WithRequestId(requestId string) *CustomError {
+	withRequestId := *customError
+	withRequestId.RequestId = requestId
+	return &withRequestId
+}
+
 func NewCustomError(httpStatus int, code string, err error) *CustomError {
 	return &CustomError{
 		HTTPStatus:  httpStatus,
@@ -59,6 +67,24 @@ func NewInternalServerError(code string, err error) *CustomError {
 	}
 }
 
+func NewTooManyRequestsError(err error) *CustomError {
+	return &CustomError{
+		HTTPStatus:  http.StatusTooManyRequests,
+		Code:        "TOO_MANY_REQUESTS",
+		Message:     err.Error(),
+		IsRetryable: true,
+	}
+}
+
+func NewServiceUnavailableError(code string, err error) *CustomError {
+	return &CustomError{
+		HTTPStatus:  http.StatusServiceUnavailable,
+		Code:        code,
+		Message:     err.Error(),
+		IsRetryable: true,
+	}
+}
+
 func NewNotFoundError(code string, err error) *CustomError {
 	return &CustomError{
 		HTTPStatus:  http.StatusNotFound,
@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/redact"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Tag is the key the request logger is stored under, in the gin context and
+// in the context of the request.
+const Tag = "logger"
+
+var (
+	componentLevels = make(map[string]zerolog.Level)
+	routeLevels     = make(map[string]zerolog.Level)
+	levelsMutex     sync.RWMutex
+)
+
+// From returns the logger of the request the context belongs to, or the
+// global logger outside of requests.
+func From(ctx context.Context) *zerolog.Logger {
+	if ctx != nil {
+		if requestLogger, ok := ctx.Value(Tag).(*zerolog.Logger); ok {
+			return requestLogger
+		}
+	}
+	return &log.Logger
+}
+
+// For returns the logger of the request tagged with a component, usually the
+// package logging, at the level configured for it.
+func For(ctx context.Context, component string) *zerolog.Logger {
+	return tag(From(ctx), component)
+}
+
+// Component returns the global logger tagged with a component, for code
+// running outside of requests.
+func Component(component string) *zerolog.Logger {
+	return tag(&log.Logger, component)
+}
+
+func tag(parent *zerolog.Logger, component string) *zerolog.Logger {
+	componentLogger := parent.With().Str("component", component).Logger()
+	if level, exists := ComponentLevel(component); exists {
+		componentLogger = componentLogger.Level(level)
+	}
+	return &componentLogger
+}
+
+// Set stores the logger of a request.
+func Set(ctx *gin.Context, requestLogger zerolog.Logger) {
+	ctx.Set(Tag, &requestLogger)
+	ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), Tag, &requestLogger))
+}
+
+// Enrich adds fields to the logger of a request, e.g. once the user is known.
+func Enrich(ctx *gin.Context, fields func(zerolog.Context) zerolog.Context) {
+	Set(ctx, fields(From(ctx).With()).Logger())
+}
+
+// SetComponentLevel makes a component log from level on. It takes precedence
+// over the level of the route.
+func SetComponentLevel(component string, level zerolog.Level) {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	componentLevels[component] = level
+}
+
+func ComponentLevel(component string) (zerolog.Level, bool) {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+	level, exists := componentLevels[component]
+	return level, exists
+}
+
+// SetRouteLevel makes the requests of a route template, e.g. `/rooms/:id`,
+// log from level on.
+func SetRouteLevel(route string, level zerolog.Level) {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	routeLevels[route] = level
+}
+
+func RouteLevel(route string) (zerolog.Level, bool) {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+	level, exists := routeLevels[route]
+	return level, exists
+}
+
+// Configure sets the default level and the overrides from a list like
+// `repositories=debug,/rooms/:id=trace`. Names starting with a slash are
+// routes. The default is set on the global logger rather than as zerolog's
+// global level, which would cap the overrides. The global logger is redacted
+// too, writing to stderr like zerolog does by default; call redact.Install
+// afterwards to log somewhere else.
+func Configure(level string, overrides string) error {
+	redact.Install(os.Stderr)
+
+	if level != "" {
+		defaultLevel, err := zerolog.ParseLevel(level)
+		if err != nil {
+			return fmt.Errorf("Invalid log level '%s': %w", level, err)
+		}
+		log.Logger = log.Logger.Level(defaultLevel)
+	}
+
+	for _, override := range strings.Split(overrides, ",") {
+		override = strings.TrimSpace(override)
+		if override == "" {
+			continue
+		}
+		name, rawLevel, found := strings.Cut(override, "=")
+		if !found {
+			return fmt.Errorf("Invalid log level override '%s', expected name=level", override)
+		}
+		overrideLevel, err := zerolog.ParseLevel(strings.TrimSpace(rawLevel))
+		if err != nil {
+			return fmt.Errorf("Invalid log level in override '%s': %w", override, err)
+		}
+		if name = strings.TrimSpace(name); strings.HasPrefix(name, "/") {
+			SetRouteLevel(name, overrideLevel)
+		} else {
+			SetComponentLevel(name, overrideLevel)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build unit
+
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ginerator/base/logger"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type signUpRequest struct {
+	Email string `json:"email" redact:"true"`
+	Name  string `json:"name"`
+}
+
+func TestConfigure(t *testing.T) {
+	err := logger.Configure("warn", "repositories=debug, /rooms/:id=trace")
+	assert.NoError(t, err)
+
+	assert.Equal(t, zerolog.WarnLevel, log.Logger.GetLevel())
+	level, exists := logger.ComponentLevel("repositories")
+	assert.True(t, exists)
+	assert.Equal(t, zerolog.DebugLevel, level)
+	level, exists = logger.RouteLevel("/rooms/:id")
+	assert.True(t, exists)
+	assert.Equal(t, zerolog.TraceLevel, level)
+	assert.Equal(t, zerolog.DebugLevel, logger.For(context.Background(), "repositories").GetLevel())
+
+	// Values logged are redacted once configured
+	output := &bytes.Buffer{}
+	outputLogger := zerolog.New(output)
+	outputLogger.Info().Interface("request", signUpRequest{Email: "jane@example.com", Name: "Jane"}).Send()
+	assert.JSONEq(t, `{"level":"info","request":{"email":"[REDACTED]","name":"Jane"}}`, output.String())
+
+	assert.Error(t, logger.Configure("", "repositories"))
+	assert.Error(t, logger.Configure("loud", ""))
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"github.com/ginerator/base/logger"
+	postgres "github.com/ginerator/base/repositories"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const logComponent = "metrics"
+
+var (
+	migrationVersionDesc = prometheus.NewDesc(
+		"db_migration_version",
+		"Currently applied migration version.",
+		[]string{"db_name"}, nil,
+	)
+	migrationDirtyDesc = prometheus.NewDesc(
+		"db_migration_dirty",
+		"Whether the last migration failed halfway, 1 if it did.",
+		[]string{"db_name"}, nil,
+	)
+)
+
+// RegisterDatabase exposes the connection pool stats of the client, e.g.
+// open, in use and idle connections and time spent waiting for one, and its
+// applied migration version.
+func RegisterDatabase(client *postgres.BunPostgresDatabaseClient) error {
+	if err := Register(collectors.NewDBStatsCollector(client.DB.DB, client.Name())); err != nil {
+		return err
+	}
+	return Register(&migrationCollector{client: client})
+}
+
+type migrationCollector struct {
+	client *postgres.BunPostgresDatabaseClient
+}
+
+func (collector *migrationCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- migrationVersionDesc
+	descs <- migrationDirtyDesc
+}
+
+func (collector *migrationCollector) Collect(metrics chan<- prometheus.Metric) {
+	version, isDirty, err := collector.client.CachedMigrationVersion()
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[METRICS] - Collect - Error reading migration version")
+		return
+	}
+
+	dirty := 0.0
+	if isDirty {
+		dirty = 1
+	}
+	name := collector.client.Name()
+	metrics <- prometheus.MustNewConstMetric(migrationVersionDesc, prometheus.GaugeValue, float64(version), name)
+	metrics <- prometheus.MustNewConstMetric(migrationDirtyDesc, prometheus.GaugeValue, dirty, name)
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"github.com/ginerator/base/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var dependencyUpDesc = prometheus.NewDesc(
+	"dependency_up",
+	"Whether a dependency is connected, 1 if it is.",
+	[]string{"dependency"}, nil,
+)
+
+// RegisterHealth exposes the connection status of the monitorable
+// dependencies of the manager, checked on every scrape.
+func RegisterHealth(appStateManager *utils.AppStateManager) error {
+	return Register(&healthCollector{appStateManager: appStateManager})
+}
+
+type healthCollector struct {
+	appStateManager *utils.AppStateManager
+}
+
+func (collector *healthCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- dependencyUpDesc
+}
+
+func (collector *healthCollector) Collect(metrics chan<- prometheus.Metric) {
+	for name, connected := range collector.appStateManager.DependencyStatuses() {
+		up := 0.0
+		if connected {
+			up = 1
+		}
+		metrics <- prometheus.MustNewConstMetric(dependencyUpDesc, prometheus.GaugeValue, up, name)
+	}
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric served at /sys/metrics. It's separate from the
+// prometheus default registry so libraries registering there don't leak in.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = NewCounter(
+		"http_requests_total",
+		"Number of HTTP requests by route, method and status.",
+		"route", "method", "status",
+	)
+	HTTPRequestDuration = NewHistogram(
+		"http_request_duration_seconds",
+		"Duration of HTTP requests by route, method and status.",
+		prometheus.DefBuckets,
+		"route", "method", "status",
+	)
+	HTTPErrors = NewCounter(
+		"http_errors_total",
+		"Number of failed HTTP requests by error code.",
+		"code",
+	)
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// NewCounter registers a counter for business metrics, e.g. orders placed.
+// It panics if a metric with the same name is registered already, so call
+// it once when the service starts.
+func NewCounter(name string, help string, labels ...string) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	Registry.MustRegister(counter)
+	return counter
+}
+
+// NewGauge registers a gauge, see NewCounter.
+func NewGauge(name string, help string, labels ...string) *prometheus.GaugeVec {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)
+	Registry.MustRegister(gauge)
+	return gauge
+}
+
+// NewHistogram registers a histogram, see NewCounter. Nil buckets use the
+// prometheus defaults, which suit durations in seconds.
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels)
+	Registry.MustRegister(histogram)
+	return histogram
+}
+
+// Register adds a collector to the registry. Registering the same collector
+// twice is a no-op.
+func Register(collector prometheus.Collector) error {
+	err := Registry.Register(collector)
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		return nil
+	}
+	return err
+}
+
+// Handler serves the registry in the prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
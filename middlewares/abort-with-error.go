@@ -0,0 +1,14 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/utils"
+)
+
+// abortWithError renders a CustomError with the id of the request, so
+// clients can quote it in bug reports.
+func abortWithError(ctx *gin.Context, err *errors.CustomError) {
+	ctx.Set(ErrorCodeTag, err.Code)
+	ctx.AbortWithStatusJSON(err.HTTPStatus, err.WithRequestId(utils.GetRequestId(ctx)))
+}
@@ -32,15 +32,19 @@ func SetUserContext(ctx *gin.Context) {
 	claims, exists := ctx.Get(CustomClaimsTag)
 	if !exists {
 		error := errors.NewUnauthorizedError(fmt.Errorf("User claims are invalid"))
-		ctx.AbortWithStatusJSON(error.HTTPStatus, error)
+		abortWithError(ctx, error)
+		return
 	}
 	customClaims := claims.(CustomClaims)
 	clientType := customClaims.ClientType
 
+	var contextUser user.User
 	switch clientType {
 	case ClientTypeUser:
-		ctx.Set(user.ContextTagUser, buildUserFromAuth(customClaims.UserMetadata))
+		contextUser = buildUserFromAuth(customClaims.UserMetadata)
 	default:
-		ctx.Set(user.ContextTagUser, buildSystemUserFromAuth(customClaims.ClientMetadata))
+		contextUser = buildSystemUserFromAuth(customClaims.ClientMetadata)
 	}
+	ctx.Set(user.ContextTagUser, contextUser)
+	enrichLoggerWithUser(ctx, contextUser.Actor(), string(contextUser.Type))
 }
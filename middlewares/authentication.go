@@ -9,9 +9,9 @@ import (
 	"github.com/MicahParks/keyfunc"
 	"github.com/gin-gonic/gin"
 	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
 	user "github.com/ginerator/base/model/users"
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/rs/zerolog/log"
 )
 
 const (
@@ -23,19 +23,19 @@ const (
 func NewJWKSProvider(jwksBaseURL string) *keyfunc.JWKS {
 	jwksURL, err := url.Parse(fmt.Sprintf("%s/.well-known/jwks.json", jwksBaseURL))
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to parse Auth0 jwks URL.")
+		logger.Component(logComponent).Fatal().Err(err).Msg("Failed to parse Auth0 jwks URL.")
 	}
 
 	options := keyfunc.Options{
 		RefreshInterval: time.Hour,
 		RefreshErrorHandler: func(err error) {
-			log.Fatal().Err(err).Msg("Failed to refresh JWKS.")
+			logger.Component(logComponent).Fatal().Err(err).Msg("Failed to refresh JWKS.")
 		},
 	}
 
 	jwks, err := keyfunc.Get(jwksURL.String(), options)
 	if err != nil {
-		log.Fatal().Err(err).Str("url", jwksURL.String()).Msg("Failed to create JWKS from resource at the given URL.")
+		logger.Component(logComponent).Fatal().Err(err).Str("url", jwksURL.String()).Msg("Failed to create JWKS from resource at the given URL.")
 	}
 	return jwks
 }
@@ -47,7 +47,7 @@ func Authenticate(provider *keyfunc.JWKS) gin.HandlerFunc {
 		jwtToken, err := stripBearerToken(authorizationHeader)
 		if err != nil {
 			error := errors.NewUnauthorizedError(err)
-			ctx.AbortWithStatusJSON(error.HTTPStatus, error)
+			abortWithError(ctx, error)
 			return
 		}
 
@@ -56,7 +56,7 @@ func Authenticate(provider *keyfunc.JWKS) gin.HandlerFunc {
 		token, err := jwt.ParseWithClaims(jwtToken, claims, provider.Keyfunc)
 		if err != nil || !token.Valid {
 			error := errors.NewUnauthorizedError(fmt.Errorf("Error parsing token: %v.", err))
-			ctx.AbortWithStatusJSON(error.HTTPStatus, error)
+			abortWithError(ctx, error)
 			return
 		}
 
@@ -9,8 +9,8 @@ import (
 )
 
 type AuthorizationPermissions struct {
-	Admin interface{}
-	Own   interface{}
+	Admin interface{} `json:"admin,omitempty"`
+	Own   interface{} `json:"own,omitempty"`
 }
 
 func CheckAuthorization(neededPermissions AuthorizationPermissions) gin.HandlerFunc {
@@ -27,7 +27,7 @@ func CheckAuthorization(neededPermissions AuthorizationPermissions) gin.HandlerF
 			}
 		}
 		error := errors.NewForbiddenError(fmt.Errorf("Permission denied."))
-		ctx.AbortWithStatusJSON(error.HTTPStatus, error)
+		abortWithError(ctx, error)
 		return
 	}
 }
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	groupPermissions      = make(map[string]AuthorizationPermissions)
+	groupPermissionsMutex sync.RWMutex
+)
+
+// AuthorizedGroup creates a route group requiring permissions, like a group
+// using CheckAuthorization, and records them so /sys/info can list the
+// permissions of each route.
+func AuthorizedGroup(router *gin.RouterGroup, relativePath string, permissions AuthorizationPermissions, handlers ...gin.HandlerFunc) *gin.RouterGroup {
+	group := router.Group(relativePath, append([]gin.HandlerFunc{CheckAuthorization(permissions)}, handlers...)...)
+	RegisterRoutePermissions(group.BasePath(), permissions)
+	return group
+}
+
+// RegisterRoutePermissions records the permissions required by the routes
+// under basePath, for groups calling CheckAuthorization themselves.
+func RegisterRoutePermissions(basePath string, permissions AuthorizationPermissions) {
+	groupPermissionsMutex.Lock()
+	defer groupPermissionsMutex.Unlock()
+	groupPermissions[basePath] = permissions
+}
+
+// RoutePermissions returns the permissions required by the closest
+// AuthorizedGroup, or registered base path, a route belongs to.
+func RoutePermissions(path string) (AuthorizationPermissions, bool) {
+	groupPermissionsMutex.RLock()
+	defer groupPermissionsMutex.RUnlock()
+
+	var (
+		permissions AuthorizationPermissions
+		matched     = -1
+	)
+	for basePath, groupPermission := range groupPermissions {
+		if len(basePath) > matched && (path == basePath || strings.HasPrefix(path, strings.TrimSuffix(basePath, "/")+"/")) {
+			permissions, matched = groupPermission, len(basePath)
+		}
+	}
+	return permissions, matched >= 0
+}
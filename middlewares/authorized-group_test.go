@@ -0,0 +1,39 @@
+//go:build unit
+
+package middlewares_test
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutePermissions(t *testing.T) {
+	router := gin.New()
+	rooms := middlewares.AuthorizedGroup(router.Group("/v1"), "/rooms", middlewares.AuthorizationPermissions{Admin: "rooms:admin", Own: "rooms:own"})
+	middlewares.AuthorizedGroup(rooms, "/:id/bookings", middlewares.AuthorizationPermissions{Admin: "bookings:admin"})
+	middlewares.RegisterRoutePermissions("/v1/items", middlewares.AuthorizationPermissions{Admin: "items:admin"})
+
+	tests := []struct {
+		path     string
+		expected interface{}
+		exists   bool
+	}{
+		{"/v1/rooms", "rooms:admin", true},
+		{"/v1/rooms/:id", "rooms:admin", true},
+		{"/v1/rooms/:id/bookings", "bookings:admin", true},
+		{"/v1/items/:id", "items:admin", true},
+		{"/v1/roomsets", nil, false},
+		{"/sys/health", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			permissions, exists := middlewares.RoutePermissions(test.path)
+			assert.Equal(t, test.exists, exists)
+			assert.Equal(t, test.expected, permissions.Admin)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/utils"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const logComponent = "middlewares"
+
+// ContextLogger stores a logger for the request in the context, with its
+// id, method, route template and client IP. The user is added once
+// authenticated. Register it after RequestId. Read it with logger.From or
+// logger.For.
+func ContextLogger() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		route := ctx.FullPath()
+		fields := log.Logger.With().
+			Str("method", ctx.Request.Method).
+			Str("route", route).
+			Str("clientIp", ctx.ClientIP())
+		if requestId := utils.GetRequestId(ctx); requestId != "" {
+			fields = fields.Str(utils.RequestIdTag, requestId)
+		}
+		if traceparent := utils.GetTraceparent(ctx); traceparent != nil {
+			fields = fields.Str("traceId", traceparent.TraceId)
+		}
+
+		requestLogger := fields.Logger()
+		if level, exists := logger.RouteLevel(route); exists {
+			requestLogger = requestLogger.Level(level)
+		}
+		logger.Set(ctx, requestLogger)
+		ctx.Next()
+	}
+}
+
+func enrichLoggerWithUser(ctx *gin.Context, userId *string, userType string) {
+	logger.Enrich(ctx, func(fields zerolog.Context) zerolog.Context {
+		if userId != nil {
+			fields = fields.Str("userId", *userId)
+		}
+		return fields.Str("userType", userType)
+	})
+}
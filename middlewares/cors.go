@@ -1,15 +1,203 @@
 package middlewares
 
 import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/config"
+	"github.com/ginerator/base/utils"
 )
 
+const anyOrigin = "*"
+
+// wildcardOrigin only allows a wildcard for subdomains, e.g. https://*.example.com
+var wildcardOrigin = regexp.MustCompile(`^https?://\*\.[^*/:]+(:\d+)?$`)
+
+type CorsOptions struct {
+	// Exact origins or subdomain wildcards. "*" allows any origin, which
+	// can't be combined with credentials.
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache the result of a preflight request
+	MaxAge time.Duration
+}
+
+// CorsOverride applies other options to the routes under a path, e.g. a
+// public API or a group used by a single frontend.
+type CorsOverride struct {
+	PathPrefix string
+	Options    CorsOptions
+}
+
+func DefaultCorsOptions() CorsOptions {
+	return CorsOptions{
+		AllowOrigins: []string{anyOrigin},
+		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		AllowHeaders: []string{
+			"Authorization", "Content-Type", "Last-Event-ID",
+			IdempotencyKeyHeader, utils.RequestIdHeader, utils.TraceparentHeader,
+		},
+		ExposeHeaders: []string{
+			"ETag", utils.RequestIdHeader, utils.TraceparentHeader, IdempotentReplayedHeader, RetryAfterHeader,
+			RateLimitLimitHeader, RateLimitRemainingHeader, RateLimitResetHeader, RateLimitPolicyHeader,
+		},
+		MaxAge: 12 * time.Hour,
+	}
+}
+
+// CorsOptionsFromConfig builds the options of an environment from the config,
+// filling what it leaves empty with the defaults.
+func CorsOptionsFromConfig(corsConfig *config.CorsConfig, env string) (CorsOptions, error) {
+	options := DefaultCorsOptions()
+	options.AllowOrigins = splitList(corsConfig.AllowedOrigins, ",")
+
+	envOrigins := make([]string, 0)
+	for _, rawEnvOrigins := range splitList(corsConfig.AllowedOriginsByEnv, ",") {
+		name, origins, found := strings.Cut(rawEnvOrigins, "=")
+		if !found {
+			return options, fmt.Errorf("Invalid CORS origins '%s', expected env=origin|origin", rawEnvOrigins)
+		}
+		if strings.TrimSpace(name) == env {
+			envOrigins = append(envOrigins, splitList(origins, "|")...)
+		}
+	}
+	if len(envOrigins) > 0 {
+		// The default of any origin is replaced by the ones of the environment
+		if len(options.AllowOrigins) == 1 && options.AllowOrigins[0] == anyOrigin {
+			options.AllowOrigins = nil
+		}
+		options.AllowOrigins = append(options.AllowOrigins, envOrigins...)
+	}
+
+	if corsConfig.AllowCredentials != "" {
+		allowCredentials, err := strconv.ParseBool(corsConfig.AllowCredentials)
+		if err != nil {
+			return options, fmt.Errorf("Invalid CORS allow credentials '%s': %w", corsConfig.AllowCredentials, err)
+		}
+		options.AllowCredentials = allowCredentials
+	}
+	if headers := splitList(corsConfig.AllowedHeaders, ","); len(headers) > 0 {
+		options.AllowHeaders = headers
+	}
+	if headers := splitList(corsConfig.ExposedHeaders, ","); len(headers) > 0 {
+		options.ExposeHeaders = headers
+	}
+	if corsConfig.MaxAge != "" {
+		maxAge, err := time.ParseDuration(corsConfig.MaxAge)
+		if err != nil {
+			return options, fmt.Errorf("Invalid CORS max age '%s': %w", corsConfig.MaxAge, err)
+		}
+		options.MaxAge = maxAge
+	}
+	return options, options.Validate()
+}
+
+// Validate rejects the combinations browsers refuse or that would let any
+// site make credentialed requests.
+func (options CorsOptions) Validate() error {
+	if len(options.AllowOrigins) == 0 {
+		return fmt.Errorf("No CORS origin allowed")
+	}
+	for _, origin := range options.AllowOrigins {
+		switch {
+		case origin == anyOrigin:
+			if len(options.AllowOrigins) > 1 {
+				return fmt.Errorf("The CORS origin '*' can't be combined with other origins")
+			}
+			if options.AllowCredentials {
+				return fmt.Errorf("The CORS origin '*' can't be combined with credentials")
+			}
+		case strings.Contains(origin, "*"):
+			if !wildcardOrigin.MatchString(origin) {
+				return fmt.Errorf("Invalid CORS origin '%s', wildcards are only allowed for subdomains, e.g. https://*.example.com", origin)
+			}
+		default:
+			parsed, err := url.Parse(origin)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || strings.TrimSuffix(parsed.Path, "/") != "" {
+				return fmt.Errorf("Invalid CORS origin '%s', expected scheme://host[:port]", origin)
+			}
+		}
+	}
+	if options.AllowCredentials {
+		for _, header := range append(options.AllowHeaders, options.ExposeHeaders...) {
+			if header == "*" {
+				return fmt.Errorf("CORS headers can't be '*' with credentials, browsers take it literally")
+			}
+		}
+	}
+	if options.MaxAge < 0 {
+		return fmt.Errorf("Invalid CORS max age %s", options.MaxAge)
+	}
+	return options.corsConfig().Validate()
+}
+
+func (options CorsOptions) corsConfig() cors.Config {
+	corsConfig := cors.Config{
+		AllowMethods:     options.AllowMethods,
+		AllowHeaders:     options.AllowHeaders,
+		ExposeHeaders:    options.ExposeHeaders,
+		AllowCredentials: options.AllowCredentials,
+		MaxAge:           options.MaxAge,
+	}
+	if len(options.AllowOrigins) == 1 && options.AllowOrigins[0] == anyOrigin {
+		corsConfig.AllowAllOrigins = true
+	} else {
+		corsConfig.AllowOrigins = options.AllowOrigins
+		corsConfig.AllowWildcard = true
+	}
+	return corsConfig
+}
+
+// NewCors validates the options and overrides, so a bad policy fails at
+// startup. The longest matching path prefix picks the options of a request.
+// It has to be a global middleware: preflight requests don't match any
+// route, so middlewares of groups never see them.
+func NewCors(options CorsOptions, overrides ...CorsOverride) (gin.HandlerFunc, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	handler := cors.New(options.corsConfig())
+
+	overrideHandlers := make([]gin.HandlerFunc, len(overrides))
+	for i, override := range overrides {
+		if err := override.Options.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid CORS override for %s: %w", override.PathPrefix, err)
+		}
+		overrideHandlers[i] = cors.New(override.Options.corsConfig())
+	}
+
+	return func(ctx *gin.Context) {
+		selected, matched := handler, -1
+		for i, override := range overrides {
+			if len(override.PathPrefix) > matched && strings.HasPrefix(ctx.Request.URL.Path, override.PathPrefix) {
+				selected, matched = overrideHandlers[i], len(override.PathPrefix)
+			}
+		}
+		selected(ctx)
+	}, nil
+}
+
+// Cors allows any origin without credentials. Use NewCors for a policy.
 func Cors() gin.HandlerFunc {
-	return cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"*"},
-		AllowCredentials: true,
-	})
+	return cors.New(DefaultCorsOptions().corsConfig())
+}
+
+func splitList(list string, separator string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(list, separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
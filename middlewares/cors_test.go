@@ -0,0 +1,91 @@
+//go:build unit
+
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/config"
+	"github.com/ginerator/base/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorsOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		creds   bool
+		valid   bool
+	}{
+		{"any origin", []string{"*"}, false, true},
+		{"any origin with credentials", []string{"*"}, true, false},
+		{"any origin with others", []string{"*", "https://app.example.com"}, false, false},
+		{"exact and subdomains", []string{"https://app.example.com", "https://*.example.com", "http://localhost:3000"}, true, true},
+		{"wildcard not a subdomain", []string{"https://app.*"}, true, false},
+		{"missing scheme", []string{"app.example.com"}, true, false},
+		{"with path", []string{"https://app.example.com/login"}, true, false},
+		{"none", []string{}, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := middlewares.DefaultCorsOptions()
+			options.AllowOrigins = test.origins
+			options.AllowCredentials = test.creds
+			err := options.Validate()
+			assert.Equal(t, test.valid, err == nil, err)
+		})
+	}
+}
+
+func TestCorsOptionsFromConfig(t *testing.T) {
+	corsConfig := &config.CorsConfig{
+		AllowedOrigins:      "*",
+		AllowedOriginsByEnv: "development=http://localhost:3000|http://localhost:5173, staging=https://*.staging.example.com",
+		AllowCredentials:    "true",
+		MaxAge:              "1h",
+	}
+
+	options, err := middlewares.CorsOptionsFromConfig(corsConfig, "development")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://localhost:3000", "http://localhost:5173"}, options.AllowOrigins)
+
+	_, err = middlewares.CorsOptionsFromConfig(corsConfig, "production")
+	assert.Error(t, err)
+}
+
+func TestNewCors(t *testing.T) {
+	options := middlewares.DefaultCorsOptions()
+	options.AllowOrigins = []string{"https://*.example.com"}
+	options.AllowCredentials = true
+	public := middlewares.DefaultCorsOptions()
+	handler, err := middlewares.NewCors(options, middlewares.CorsOverride{PathPrefix: "/public", Options: public})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handler)
+
+	tests := []struct {
+		path     string
+		origin   string
+		expected string
+	}{
+		{"/rooms", "https://app.example.com", "https://app.example.com"},
+		{"/rooms", "https://evil.com", ""},
+		{"/public/rooms", "https://evil.com", "*"},
+	}
+	for _, test := range tests {
+		t.Run(test.path+" "+test.origin, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodOptions, test.path, nil)
+			request.Header.Set("Origin", test.origin)
+			request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+			assert.Equal(t, test.expected, response.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
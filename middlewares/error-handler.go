@@ -3,7 +3,7 @@ package middlewares
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/ginerator/base/errors"
-	"github.com/rs/zerolog/log"
+	"github.com/ginerator/base/logger"
 )
 
 func ErrorHandler() gin.HandlerFunc {
@@ -13,12 +13,12 @@ func ErrorHandler() gin.HandlerFunc {
 
 		if len(detectedErrors) > 0 {
 			err := detectedErrors[0].Err
-			log.Error().Err(err)
+			logger.For(ctx, logComponent).Error().Err(err).Msg("[ERROR HANDLER] - Request failed")
 			parsedError, ok := err.(*errors.CustomError)
 			if !ok {
 				parsedError = errors.NewInternalServerError("UNKNOWN_ERROR", err)
 			}
-			ctx.AbortWithStatusJSON(parsedError.HTTPStatus, parsedError)
+			abortWithError(ctx, parsedError)
 			return
 		}
 	}
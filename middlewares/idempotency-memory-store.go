@@ -0,0 +1,92 @@
+package middlewares
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ginerator/base/model/idempotency"
+)
+
+const memoryStoreSweepInterval = time.Minute
+
+// MemoryIdempotencyStore keeps idempotency records in the process. Only
+// suited to single replica services and tests.
+type MemoryIdempotencyStore struct {
+	records   map[string]idempotency.Record
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records:   make(map[string]idempotency.Record),
+		lastSweep: time.Now(),
+	}
+}
+
+func memoryStoreKey(scope string, key string) string {
+	return scope + "\x00" + key
+}
+
+func (store *MemoryIdempotencyStore) Begin(_ context.Context, record idempotency.Record) (*idempotency.Record, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	store.sweep(now)
+
+	storeKey := memoryStoreKey(record.Scope, record.Key)
+	if existing, exists := store.records[storeKey]; exists && isLive(existing, now) {
+		return &existing, nil
+	}
+	store.records[storeKey] = record
+	return nil, nil
+}
+
+func (store *MemoryIdempotencyStore) Complete(_ context.Context, record idempotency.Record) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	storeKey := memoryStoreKey(record.Scope, record.Key)
+	if store.holdsLock(storeKey, record) {
+		store.records[storeKey] = record
+	}
+	return nil
+}
+
+func (store *MemoryIdempotencyStore) Release(_ context.Context, record idempotency.Record) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	storeKey := memoryStoreKey(record.Scope, record.Key)
+	if store.holdsLock(storeKey, record) {
+		delete(store.records, storeKey)
+	}
+	return nil
+}
+
+// holdsLock tells whether the stored record is still the in flight one of the request.
+func (store *MemoryIdempotencyStore) holdsLock(storeKey string, record idempotency.Record) bool {
+	existing, exists := store.records[storeKey]
+	return exists && !existing.Completed() && existing.LockedUntil.Equal(record.LockedUntil)
+}
+
+func (store *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(store.lastSweep) < memoryStoreSweepInterval {
+		return
+	}
+	store.lastSweep = now
+	for storeKey, record := range store.records {
+		if !isLive(record, now) {
+			delete(store.records, storeKey)
+		}
+	}
+}
+
+// isLive tells whether a record still answers for its key: it isn't expired
+// and, if in flight, its request wasn't abandoned.
+func isLive(record idempotency.Record, now time.Time) bool {
+	if now.After(record.ExpiresAt) {
+		return false
+	}
+	return record.Completed() || now.Before(record.LockedUntil)
+}
@@ -0,0 +1,216 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/idempotency"
+	user "github.com/ginerator/base/model/users"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// IdempotencyStore keeps the responses of requests sent with an Idempotency-Key.
+type IdempotencyStore interface {
+	// Begin stores the record as in flight, unless a live record exists for
+	// its scope and key. That one is returned instead.
+	Begin(ctx context.Context, record idempotency.Record) (*idempotency.Record, error)
+	// Complete stores the response of an in flight record.
+	Complete(ctx context.Context, record idempotency.Record) error
+	// Release deletes an in flight record so the request can be retried. A
+	// record taken over by another request since, with another LockedUntil,
+	// is left alone.
+	Release(ctx context.Context, record idempotency.Record) error
+}
+
+type IdempotencyOptions struct {
+	// TTL is how long responses are replayed for
+	TTL time.Duration
+	// InFlightTimeout is how long a request may stay in flight before its key
+	// is considered abandoned, e.g. by a crashed replica
+	InFlightTimeout time.Duration
+	// Required rejects requests without an Idempotency-Key
+	Required bool
+	Methods  []string
+}
+
+func DefaultIdempotencyOptions() IdempotencyOptions {
+	return IdempotencyOptions{
+		TTL:             24 * time.Hour,
+		InFlightTimeout: time.Minute,
+		Methods:         []string{http.MethodPost},
+	}
+}
+
+// Idempotency replays the original response of requests repeated with the same
+// Idempotency-Key by the same user. Keys reused with a different request are
+// rejected with 422, and repeats of a request still in flight with 409.
+// Responses with a 5xx status aren't stored, so those can be retried. Keys
+// are scoped to the user, so register it on authenticated groups after
+// Authenticate, and before an ErrorHandler on the same group to see the
+// errors it renders. Requests without a user are let through untouched.
+func Idempotency(store IdempotencyStore, options IdempotencyOptions) gin.HandlerFunc {
+	defaults := DefaultIdempotencyOptions()
+	if options.TTL <= 0 {
+		options.TTL = defaults.TTL
+	}
+	if options.InFlightTimeout <= 0 {
+		options.InFlightTimeout = defaults.InFlightTimeout
+	}
+	if len(options.Methods) == 0 {
+		options.Methods = defaults.Methods
+	}
+	methods := make(map[string]struct{}, len(options.Methods))
+	for _, method := range options.Methods {
+		methods[method] = struct{}{}
+	}
+
+	return func(ctx *gin.Context) {
+		if _, applies := methods[ctx.Request.Method]; !applies {
+			ctx.Next()
+			return
+		}
+
+		scope, exists := idempotencyScope(ctx)
+		if !exists {
+			ctx.Next()
+			return
+		}
+
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			if options.Required {
+				abortWithError(ctx, errors.NewBadRequest("IDEMPOTENCY_KEY_MISSING", fmt.Errorf("The %s header is required.", IdempotencyKeyHeader)))
+				return
+			}
+			ctx.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			abortWithError(ctx, errors.NewBadRequest("IDEMPOTENCY_KEY_INVALID", fmt.Errorf("The %s header can't exceed %d characters.", IdempotencyKeyHeader, maxIdempotencyKeyLength)))
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			abortWithError(ctx, errors.NewBadRequest("INVALID_PAYLOAD", err))
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Postgres keeps microseconds, LockedUntil has to compare equal once stored
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		record := idempotency.Record{
+			Scope:       scope,
+			Key:         key,
+			RequestHash: hashRequest(ctx.Request, body),
+			LockedUntil: now.Add(options.InFlightTimeout),
+			ExpiresAt:   now.Add(options.TTL),
+			CreatedAt:   now,
+		}
+
+		existing, err := store.Begin(ctx, record)
+		if err != nil {
+			logger.For(ctx, logComponent).Error().Err(err).Str("key", key).Msg("[IDEMPOTENCY] - Begin - Error storing key")
+			abortWithError(ctx, errors.NewInternalServerError("UNKNOWN_ERROR", err))
+			return
+		}
+		if existing != nil {
+			replay(ctx, *existing, record)
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+		completed := false
+		defer func() {
+			// Panics and unrendered errors release the key as well
+			if !completed {
+				if err := store.Release(context.Background(), record); err != nil {
+					logger.For(ctx, logComponent).Error().Err(err).Str("key", key).Msg("[IDEMPOTENCY] - Release - Error releasing key")
+				}
+			}
+		}()
+
+		ctx.Next()
+		ctx.Writer = writer.ResponseWriter
+
+		status := writer.Status()
+		if !writer.Written() || status >= http.StatusInternalServerError {
+			return
+		}
+		record.Status = status
+		record.Body = writer.body.Bytes()
+		if contentType := writer.Header().Get("Content-Type"); contentType != "" {
+			record.ContentType = &contentType
+		}
+		if err := store.Complete(context.Background(), record); err != nil {
+			logger.For(ctx, logComponent).Error().Err(err).Str("key", key).Msg("[IDEMPOTENCY] - Complete - Error storing response")
+			return
+		}
+		completed = true
+	}
+}
+
+func replay(ctx *gin.Context, existing idempotency.Record, record idempotency.Record) {
+	if existing.RequestHash != record.RequestHash {
+		abortWithError(ctx, errors.NewCustomError(http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED", fmt.Errorf("Idempotency-Key '%s' was already used with a different request.", record.Key)))
+		return
+	}
+	if !existing.Completed() {
+		abortWithError(ctx, errors.NewCustomError(http.StatusConflict, "IDEMPOTENCY_KEY_IN_USE", fmt.Errorf("A request with Idempotency-Key '%s' is still in progress.", record.Key)))
+		return
+	}
+
+	contentType := "application/json; charset=utf-8"
+	if existing.ContentType != nil {
+		contentType = *existing.ContentType
+	}
+	ctx.Header(IdempotentReplayedHeader, "true")
+	ctx.Data(existing.Status, contentType, existing.Body)
+	ctx.Abort()
+}
+
+// idempotencyScope namespaces keys by user, so clients can't replay the
+// responses of others.
+func idempotencyScope(ctx *gin.Context) (string, bool) {
+	if contextUser := user.GetUser(ctx); contextUser != nil && contextUser.Actor() != nil {
+		return "user:" + *contextUser.Actor(), true
+	}
+	return "", false
+}
+
+func hashRequest(request *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(request.Method + " " + request.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (writer *recordingWriter) Write(data []byte) (int, error) {
+	writer.body.Write(data)
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *recordingWriter) WriteString(data string) (int, error) {
+	writer.body.WriteString(data)
+	return writer.ResponseWriter.WriteString(data)
+}
@@ -0,0 +1,149 @@
+//go:build unit
+
+package middlewares_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/middlewares"
+	user "github.com/ginerator/base/model/users"
+	"github.com/stretchr/testify/assert"
+)
+
+const testUserHeader = "X-Test-User"
+
+// authenticateTestUser stands for Authenticate, with the user id in a header.
+func authenticateTestUser(ctx *gin.Context) {
+	if userId := ctx.GetHeader(testUserHeader); userId != "" {
+		ctx.Set(user.ContextTagUser, user.User{Id: &userId, Type: user.UserTypePerson})
+	}
+}
+
+func newIdempotentRouter(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authenticateTestUser, middlewares.Idempotency(middlewares.NewMemoryIdempotencyStore(), middlewares.DefaultIdempotencyOptions()))
+	router.POST("/rooms", handler)
+	return router
+}
+
+func post(router *gin.Engine, key string, body string) *httptest.ResponseRecorder {
+	return postAs(router, "user-1", key, body)
+}
+
+func postAs(router *gin.Engine, userId string, key string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/rooms", strings.NewReader(body))
+	request.Header.Set(middlewares.IdempotencyKeyHeader, key)
+	request.Header.Set(testUserHeader, userId)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(func(ctx *gin.Context) {
+		calls++
+		ctx.JSON(http.StatusCreated, gin.H{"data": calls})
+	})
+
+	first := post(router, "key-1", `{"name":"a"}`)
+	second := post(router, "key-1", `{"name":"a"}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get(middlewares.IdempotentReplayedHeader))
+}
+
+func TestIdempotencyScopesKeysByUser(t *testing.T) {
+	router := newIdempotentRouter(func(ctx *gin.Context) {
+		ctx.JSON(http.StatusCreated, gin.H{"data": ctx.GetHeader(testUserHeader)})
+	})
+
+	first := postAs(router, "user-1", "key-1", `{"name":"a"}`)
+	second := postAs(router, "user-2", "key-1", `{"name":"a"}`)
+
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.JSONEq(t, `{"data":"user-1"}`, first.Body.String())
+	assert.JSONEq(t, `{"data":"user-2"}`, second.Body.String())
+	assert.Empty(t, second.Header().Get(middlewares.IdempotentReplayedHeader))
+}
+
+func TestIdempotencyIgnoresAnonymousRequests(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(func(ctx *gin.Context) {
+		calls++
+		ctx.JSON(http.StatusCreated, gin.H{})
+	})
+
+	postAs(router, "", "key-1", `{"name":"a"}`)
+	second := postAs(router, "", "key-1", `{"name":"a"}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, second.Header().Get(middlewares.IdempotentReplayedHeader))
+}
+
+func TestIdempotencyRejectsReusedKey(t *testing.T) {
+	router := newIdempotentRouter(func(ctx *gin.Context) {
+		ctx.JSON(http.StatusCreated, gin.H{})
+	})
+
+	post(router, "key-1", `{"name":"a"}`)
+	response := post(router, "key-1", `{"name":"b"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, response.Code)
+}
+
+func TestIdempotencyRejectsInFlightDuplicate(t *testing.T) {
+	var router *gin.Engine
+	var duplicate *httptest.ResponseRecorder
+	router = newIdempotentRouter(func(ctx *gin.Context) {
+		if duplicate == nil {
+			duplicate = post(router, "key-1", `{"name":"a"}`)
+		}
+		ctx.JSON(http.StatusCreated, gin.H{})
+	})
+
+	post(router, "key-1", `{"name":"a"}`)
+
+	assert.Equal(t, http.StatusConflict, duplicate.Code)
+}
+
+func TestIdempotencyReleasesServerErrors(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(func(ctx *gin.Context) {
+		calls++
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{})
+	})
+
+	post(router, "key-1", `{"name":"a"}`)
+	post(router, "key-1", `{"name":"a"}`)
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyStoresRenderedErrors(t *testing.T) {
+	calls := 0
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authenticateTestUser, middlewares.Idempotency(middlewares.NewMemoryIdempotencyStore(), middlewares.DefaultIdempotencyOptions()), middlewares.ErrorHandler())
+	router.POST("/rooms", func(ctx *gin.Context) {
+		calls++
+		ctx.Error(errors.NewNotFoundError("ROOM_NOT_FOUND", fmt.Errorf("Room not found.")))
+	})
+
+	first := post(router, "key-1", `{"name":"a"}`)
+	second := post(router, "key-1", `{"name":"a"}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusNotFound, first.Code)
+	assert.Equal(t, http.StatusNotFound, second.Code)
+	assert.Equal(t, "true", second.Header().Get(middlewares.IdempotentReplayedHeader))
+}
@@ -1,10 +1,182 @@
 package middlewares
 
 import (
-	ginLogger "github.com/gin-contrib/logger"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
+	user "github.com/ginerator/base/model/users"
+	"github.com/ginerator/base/utils"
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+)
+
+const (
+	accessLogComponent = "access"
+	// ErrorCodeTag is where the code of the CustomError a request failed with is stored
+	ErrorCodeTag   = "errorCode"
+	redactedValue  = "[REDACTED]"
+	unmatchedRoute = "unmatched"
 )
 
+type AccessLogOptions struct {
+	SkipPaths []string
+	// SuccessSampleRate is the share of requests answered below 400 that get
+	// logged, between 0 and 1. Failed requests are always logged.
+	SuccessSampleRate float64
+	// Headers are the request headers logged, everything else is left out
+	Headers []string
+	// RedactedQueryParams are logged with their value masked
+	RedactedQueryParams []string
+}
+
+func DefaultAccessLogOptions() AccessLogOptions {
+	return AccessLogOptions{
+		SkipPaths:         []string{"/sys/health", "/sys/live", "/sys/ready", "/sys/startup"},
+		SuccessSampleRate: 1,
+		Headers:           []string{"User-Agent"},
+		RedactedQueryParams: []string{
+			"token", "access_token", "id_token", "refresh_token", "code",
+			"api_key", "apikey", "key", "password", "secret", "signature",
+		},
+	}
+}
+
+// Logger logs every request with the default options.
 func Logger() gin.HandlerFunc {
-	return ginLogger.SetLogger(ginLogger.WithSkipPath([]string{"/sys/health"}))
+	return AccessLog(DefaultAccessLogOptions())
+}
+
+// AccessLog emits one structured event per request once it's answered, at
+// error level for 5xx, warn for 4xx and info otherwise. Register it after
+// RequestId to get the ids in the events, and before ErrorHandler to see the
+// errors it renders.
+func AccessLog(options AccessLogOptions) gin.HandlerFunc {
+	skipPaths := lo.SliceToMap(options.SkipPaths, func(path string) (string, struct{}) {
+		return path, struct{}{}
+	})
+	redactedQueryParams := lo.SliceToMap(options.RedactedQueryParams, func(param string) (string, struct{}) {
+		return strings.ToLower(param), struct{}{}
+	})
+
+	return func(ctx *gin.Context) {
+		if _, skip := skipPaths[ctx.Request.URL.Path]; skip {
+			ctx.Next()
+			return
+		}
+
+		start := time.Now()
+		body := &countingReader{ReadCloser: ctx.Request.Body}
+		if ctx.Request.Body != nil {
+			ctx.Request.Body = body
+		}
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		if status < http.StatusBadRequest && options.SuccessSampleRate < 1 && rand.Float64() >= options.SuccessSampleRate {
+			return
+		}
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		accessLogger := logger.Component(accessLogComponent)
+		event := accessLogger.WithLevel(accessLogLevel(status))
+		if event == nil {
+			return
+		}
+		event.
+			Str("method", ctx.Request.Method).
+			Str("route", route).
+			Int("status", status).
+			Dur("latency", time.Since(start)).
+			Int64("bytesIn", body.bytes).
+			Int("bytesOut", lo.Max([]int{ctx.Writer.Size(), 0})).
+			Str("clientIp", ctx.ClientIP())
+
+		if rawQuery := ctx.Request.URL.RawQuery; rawQuery != "" {
+			event.Str("query", redactQuery(ctx.Request.URL.Query(), redactedQueryParams))
+		}
+		if requestId := utils.GetRequestId(ctx); requestId != "" {
+			event.Str(utils.RequestIdTag, requestId)
+		}
+		if traceparent := utils.GetTraceparent(ctx); traceparent != nil {
+			event.Str("traceId", traceparent.TraceId)
+		}
+		if contextUser := user.GetUser(ctx); contextUser != nil {
+			if actor := contextUser.Actor(); actor != nil {
+				event.Str("userId", *actor)
+			}
+			event.Str("userType", string(contextUser.Type))
+		}
+		if code := errorCode(ctx); code != "" {
+			event.Str(ErrorCodeTag, code)
+		}
+		if len(options.Headers) > 0 {
+			headers := zerolog.Dict()
+			for _, header := range options.Headers {
+				if value := ctx.GetHeader(header); value != "" {
+					headers.Str(header, value)
+				}
+			}
+			event.Dict("headers", headers)
+		}
+		event.Msg("Request served")
+	}
+}
+
+func accessLogLevel(status int) zerolog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return zerolog.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.InfoLevel
+	}
+}
+
+// errorCode returns the code of the error the request failed with,
+// whether rendered by a middleware or left in the context by a handler.
+func errorCode(ctx *gin.Context) string {
+	if errorCode := ctx.GetString(ErrorCodeTag); errorCode != "" {
+		return errorCode
+	}
+	for _, ginError := range ctx.Errors {
+		if customError, ok := ginError.Err.(*errors.CustomError); ok {
+			return customError.Code
+		}
+	}
+	return ""
+}
+
+func redactQuery(query url.Values, redactedQueryParams map[string]struct{}) string {
+	for param, values := range query {
+		if _, redacted := redactedQueryParams[strings.ToLower(param)]; redacted {
+			for i := range values {
+				values[i] = redactedValue
+			}
+		}
+	}
+	return query.Encode()
+}
+
+type countingReader struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (reader *countingReader) Read(data []byte) (int, error) {
+	read, err := reader.ReadCloser.Read(data)
+	reader.bytes += int64(read)
+	return read, err
 }
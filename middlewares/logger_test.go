@@ -0,0 +1,57 @@
+//go:build unit
+
+package middlewares_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/middlewares"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	output := new(bytes.Buffer)
+	previousLogger := log.Logger
+	log.Logger = zerolog.New(output)
+	defer func() { log.Logger = previousLogger }()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.Logger(), middlewares.ErrorHandler())
+	router.GET("/rooms/:id", func(ctx *gin.Context) {
+		ctx.Error(errors.NewNotFoundError("NOT_FOUND", fmt.Errorf("Room not found.")))
+	})
+	probes := []string{"/sys/health", "/sys/live", "/sys/ready", "/sys/startup"}
+	for _, probe := range probes {
+		router.GET(probe, func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, probe, nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/rooms/1?token=abc&page=2", nil))
+
+	event := make(map[string]interface{})
+	for _, line := range bytes.Split(bytes.TrimSpace(output.Bytes()), []byte("\n")) {
+		if bytes.Contains(line, []byte("Request served")) {
+			assert.NoError(t, json.Unmarshal(line, &event))
+		}
+	}
+
+	assert.Equal(t, "/rooms/:id", event["route"])
+	assert.Equal(t, float64(http.StatusNotFound), event["status"])
+	assert.Equal(t, "warn", event["level"])
+	assert.Equal(t, "NOT_FOUND", event["errorCode"])
+	assert.Equal(t, "page=2&token=%5BREDACTED%5D", event["query"])
+	for _, probe := range probes {
+		assert.NotContains(t, output.String(), probe)
+	}
+}
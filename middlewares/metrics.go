@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/metrics"
+)
+
+// Metrics records the count and duration of requests by route, method and
+// status, and failed requests by error code. Like AccessLog, register it
+// before ErrorHandler so it sees the final status.
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(ctx.Writer.Status())
+		metrics.HTTPRequests.WithLabelValues(route, ctx.Request.Method, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, ctx.Request.Method, status).Observe(time.Since(start).Seconds())
+		if code := errorCode(ctx); code != "" {
+			metrics.HTTPErrors.WithLabelValues(code).Inc()
+		}
+	}
+}
@@ -0,0 +1,35 @@
+//go:build unit
+
+package middlewares_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/metrics"
+	"github.com/ginerator/base/middlewares"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.Metrics(), middlewares.ErrorHandler())
+	router.GET("/rooms/:id", func(ctx *gin.Context) {
+		ctx.Error(errors.NewNotFoundError("ROOM_NOT_FOUND", fmt.Errorf("room not found")))
+	})
+
+	for i := 0; i < 2; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/rooms/1", nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/rooms/:id", http.MethodGet, "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("unmatched", http.MethodGet, "404")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.HTTPErrors.WithLabelValues("ROOM_NOT_FOUND")))
+}
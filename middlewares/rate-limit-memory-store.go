@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/ginerator/base/model/ratelimit"
+)
+
+const (
+	rateLimitShards              = 64
+	rateLimitMemorySweepInterval = time.Minute
+)
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	quota     ratelimit.Quota
+}
+
+type rateLimitShard struct {
+	buckets map[string]*tokenBucket
+	mutex   sync.Mutex
+}
+
+// MemoryRateLimitStore keeps the buckets in the process, spread over shards
+// so concurrent requests rarely wait on the same lock. Limits apply per replica.
+type MemoryRateLimitStore struct {
+	shards    [rateLimitShards]*rateLimitShard
+	lastSweep time.Time
+	sweeping  sync.Mutex
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	store := &MemoryRateLimitStore{lastSweep: time.Now()}
+	for i := range store.shards {
+		store.shards[i] = &rateLimitShard{buckets: make(map[string]*tokenBucket)}
+	}
+	return store
+}
+
+func (store *MemoryRateLimitStore) Take(_ context.Context, key string, quota ratelimit.Quota, now time.Time) (ratelimit.Result, error) {
+	store.sweep(now)
+
+	shard := store.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	bucket, exists := shard.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(quota.Limit), updatedAt: now}
+		shard.buckets[key] = bucket
+	}
+	bucket.tokens = quota.Refill(bucket.tokens, now.Sub(bucket.updatedAt))
+	bucket.updatedAt = now
+	bucket.quota = quota
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	return ratelimit.NewResult(quota, bucket.tokens, allowed), nil
+}
+
+func (store *MemoryRateLimitStore) shard(key string) *rateLimitShard {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return store.shards[hash.Sum32()%rateLimitShards]
+}
+
+// sweep drops the buckets that refilled completely, they're the same as new ones.
+func (store *MemoryRateLimitStore) sweep(now time.Time) {
+	if !store.sweeping.TryLock() {
+		return
+	}
+	defer store.sweeping.Unlock()
+	if now.Sub(store.lastSweep) < rateLimitMemorySweepInterval {
+		return
+	}
+	store.lastSweep = now
+
+	for _, shard := range store.shards {
+		shard.mutex.Lock()
+		for key, bucket := range shard.buckets {
+			if bucket.quota.Refill(bucket.tokens, now.Sub(bucket.updatedAt)) >= float64(bucket.quota.Limit) {
+				delete(shard.buckets, key)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+}
@@ -0,0 +1,150 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/ratelimit"
+	user "github.com/ginerator/base/model/users"
+)
+
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+	RetryAfterHeader         = "Retry-After"
+)
+
+// RateLimitStore keeps the token buckets of the rate limiter.
+type RateLimitStore interface {
+	// Take removes a token from the bucket of key, if it has one.
+	Take(ctx context.Context, key string, quota ratelimit.Quota, now time.Time) (ratelimit.Result, error)
+}
+
+type RateLimitOptions struct {
+	// Name namespaces the buckets, so routes limited separately don't share them
+	Name  string
+	Quota ratelimit.Quota
+	// RoleQuotas override Quota for callers with a role. With several roles
+	// the most generous quota applies.
+	RoleQuotas   map[string]ratelimit.Quota
+	APIKeyHeader string
+	// ValidateAPIKey tells whether the API key of a request is a real one.
+	// Without it API keys are ignored and anonymous callers are limited by IP,
+	// otherwise sending a new key on every request would get a new bucket.
+	ValidateAPIKey func(ctx *gin.Context, apiKey string) bool
+	// KeyFunc overrides how callers are identified
+	KeyFunc func(ctx *gin.Context) string
+}
+
+func DefaultRateLimitOptions() RateLimitOptions {
+	return RateLimitOptions{
+		Name:         "default",
+		Quota:        ratelimit.Quota{Limit: 100, Period: time.Minute},
+		APIKeyHeader: "X-API-Key",
+	}
+}
+
+// RateLimit limits requests with a token bucket per caller: the authenticated
+// user, the system client, the validated API key or the IP, in that order. Register it
+// after Authenticate for user and role quotas to apply, on a group or route to
+// give it its own quota. When the store fails requests are let through.
+func RateLimit(store RateLimitStore, options RateLimitOptions) gin.HandlerFunc {
+	defaults := DefaultRateLimitOptions()
+	if options.Name == "" {
+		options.Name = defaults.Name
+	}
+	if options.Quota.Limit <= 0 || options.Quota.Period <= 0 {
+		options.Quota = defaults.Quota
+	}
+	if options.APIKeyHeader == "" {
+		options.APIKeyHeader = defaults.APIKeyHeader
+	}
+	if options.KeyFunc == nil {
+		options.KeyFunc = func(ctx *gin.Context) string {
+			return rateLimitKey(ctx, options.APIKeyHeader, options.ValidateAPIKey)
+		}
+	}
+
+	return func(ctx *gin.Context) {
+		quota := rateLimitQuota(ctx, options)
+		key := options.Name + ":" + options.KeyFunc(ctx)
+
+		result, err := store.Take(ctx, key, quota, time.Now())
+		if err != nil {
+			logger.For(ctx, logComponent).Error().Err(err).Str("key", key).Msg("[RATE LIMIT] - Take - Error taking token, letting request through")
+			ctx.Next()
+			return
+		}
+
+		ctx.Header(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+		ctx.Header(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+		ctx.Header(RateLimitResetHeader, strconv.Itoa(ceilSeconds(result.Reset)))
+		ctx.Header(RateLimitPolicyHeader, fmt.Sprintf("%d;w=%d", quota.Limit, ceilSeconds(quota.Period)))
+		if result.Allowed {
+			ctx.Next()
+			return
+		}
+
+		retryAfter := ceilSeconds(result.RetryAfter)
+		logger.For(ctx, logComponent).Warn().Str("key", key).Int("retryAfter", retryAfter).Msg("[RATE LIMIT] - RateLimit - Request rejected")
+		ctx.Header(RetryAfterHeader, strconv.Itoa(retryAfter))
+		error := errors.NewTooManyRequestsError(fmt.Errorf("Rate limit of %d requests per %s exceeded, retry in %d seconds.", quota.Limit, quota.Period, retryAfter))
+		abortWithError(ctx, error)
+	}
+}
+
+func rateLimitKey(ctx *gin.Context, apiKeyHeader string, validateAPIKey func(*gin.Context, string) bool) string {
+	if contextUser := user.GetUser(ctx); contextUser != nil {
+		if actor := contextUser.Actor(); actor != nil {
+			if contextUser.Type == user.UserTypeSystem {
+				return "client:" + *actor
+			}
+			return "user:" + *actor
+		}
+	}
+	if apiKey := ctx.GetHeader(apiKeyHeader); apiKey != "" && validateAPIKey != nil && validateAPIKey(ctx, apiKey) {
+		// Keys are hashed so they don't end up in the store
+		hash := sha256.Sum256([]byte(apiKey))
+		return "apikey:" + hex.EncodeToString(hash[:8])
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+func rateLimitQuota(ctx *gin.Context, options RateLimitOptions) ratelimit.Quota {
+	quota := options.Quota
+	claims, exists := ctx.Get(CustomClaimsTag)
+	if !exists || len(options.RoleQuotas) == 0 {
+		return quota
+	}
+	customClaims, ok := claims.(CustomClaims)
+	if !ok {
+		return quota
+	}
+
+	found := false
+	for _, role := range customClaims.Roles {
+		roleQuota, exists := options.RoleQuotas[role]
+		if !exists {
+			continue
+		}
+		if !found || roleQuota.Rate() > quota.Rate() {
+			quota = roleQuota
+			found = true
+		}
+	}
+	return quota
+}
+
+func ceilSeconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
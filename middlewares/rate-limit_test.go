@@ -0,0 +1,87 @@
+//go:build unit
+
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/middlewares"
+	"github.com/ginerator/base/model/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	options := middlewares.DefaultRateLimitOptions()
+	options.Quota = ratelimit.Quota{Limit: 2, Period: time.Minute}
+
+	router := gin.New()
+	router.Use(middlewares.RateLimit(middlewares.NewMemoryRateLimitStore(), options))
+	router.GET("/rooms", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	get := func(ip string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/rooms", nil)
+		request.RemoteAddr = ip + ":1234"
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusOK, get("10.0.0.1").Code)
+	second := get("10.0.0.1")
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "0", second.Header().Get(middlewares.RateLimitRemainingHeader))
+
+	rejected := get("10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, rejected.Code)
+	assert.Equal(t, "30", rejected.Header().Get(middlewares.RetryAfterHeader))
+
+	assert.Equal(t, http.StatusOK, get("10.0.0.2").Code)
+}
+
+func TestRateLimitAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	options := middlewares.DefaultRateLimitOptions()
+	options.Quota = ratelimit.Quota{Limit: 1, Period: time.Minute}
+	options.ValidateAPIKey = func(ctx *gin.Context, apiKey string) bool {
+		return apiKey == "partner-key"
+	}
+
+	router := gin.New()
+	router.Use(middlewares.RateLimit(middlewares.NewMemoryRateLimitStore(), options))
+	router.GET("/rooms", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	get := func(apiKey string) int {
+		request := httptest.NewRequest(http.MethodGet, "/rooms", nil)
+		request.RemoteAddr = "10.0.0.1:1234"
+		request.Header.Set(options.APIKeyHeader, apiKey)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// Unknown keys share the bucket of the IP, rotating them doesn't reset it
+	assert.Equal(t, http.StatusOK, get("random-1"))
+	assert.Equal(t, http.StatusTooManyRequests, get("random-2"))
+	assert.Equal(t, http.StatusTooManyRequests, get("random-3"))
+
+	// A valid key has a bucket of its own
+	assert.Equal(t, http.StatusOK, get("partner-key"))
+	assert.Equal(t, http.StatusTooManyRequests, get("partner-key"))
+}
+
+func TestQuotaRefill(t *testing.T) {
+	quota := ratelimit.Quota{Limit: 10, Period: 10 * time.Second}
+
+	assert.Equal(t, 5.0, quota.Refill(0, 5*time.Second))
+	assert.Equal(t, 10.0, quota.Refill(8, time.Hour))
+	assert.Equal(t, 3.0, quota.Refill(3, -time.Second))
+}
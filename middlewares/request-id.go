@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/utils"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const maxRequestIdLength = 128
+
+var correlationHookOnce sync.Once
+
+// RequestId accepts or generates the X-Request-ID and W3C traceparent of a
+// request, stores them in the gin context and the context of the request,
+// and echoes them in the response. Log lines given the context with
+// `.Ctx(ctx)` carry both. Register it first so every other middleware sees them.
+func RequestId() gin.HandlerFunc {
+	correlationHookOnce.Do(func() {
+		log.Logger = log.Logger.Hook(utils.CorrelationHook{})
+	})
+
+	return func(ctx *gin.Context) {
+		traceparent, valid := utils.ParseTraceparent(ctx.GetHeader(utils.TraceparentHeader))
+		if !valid {
+			traceparent = utils.NewTraceparent()
+		}
+
+		requestId := ctx.GetHeader(utils.RequestIdHeader)
+		if !isValidRequestId(requestId) {
+			requestId = uuid.NewString()
+		}
+
+		ctx.Set(utils.RequestIdTag, requestId)
+		ctx.Set(utils.TraceparentTag, traceparent)
+		requestCtx := context.WithValue(ctx.Request.Context(), utils.RequestIdTag, requestId)
+		requestCtx = context.WithValue(requestCtx, utils.TraceparentTag, traceparent)
+		ctx.Request = ctx.Request.WithContext(requestCtx)
+
+		ctx.Header(utils.RequestIdHeader, requestId)
+		ctx.Header(utils.TraceparentHeader, traceparent.String())
+		ctx.Next()
+	}
+}
+
+// isValidRequestId rejects ids that would pollute logs: empty, too long or
+// with non printable characters.
+func isValidRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		return false
+	}
+	for _, character := range requestId {
+		if character < 0x21 || character > 0x7e {
+			return false
+		}
+	}
+	return true
+}
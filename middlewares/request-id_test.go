@@ -0,0 +1,59 @@
+//go:build unit
+
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/middlewares"
+	"github.com/ginerator/base/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestId(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.RequestId())
+	router.GET("/rooms", func(ctx *gin.Context) {
+		assert.Equal(t, ctx.Writer.Header().Get(utils.RequestIdHeader), utils.GetRequestId(ctx.Request.Context()))
+		ctx.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name              string
+		requestId         string
+		traceparent       string
+		expectedRequestId string
+		expectedTraceId   string
+	}{
+		{"accepted", "abc-123", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "abc-123", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"generated", "", "", "", ""},
+		{"invalid", "abc 123", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/rooms", nil)
+			request.Header.Set(utils.RequestIdHeader, test.requestId)
+			request.Header.Set(utils.TraceparentHeader, test.traceparent)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			requestId := recorder.Header().Get(utils.RequestIdHeader)
+			traceparent, valid := utils.ParseTraceparent(recorder.Header().Get(utils.TraceparentHeader))
+			assert.NotEmpty(t, requestId)
+			assert.True(t, valid)
+			if test.expectedRequestId != "" {
+				assert.Equal(t, test.expectedRequestId, requestId)
+			} else {
+				assert.NotEqual(t, test.requestId, requestId)
+			}
+			if test.expectedTraceId != "" {
+				assert.Equal(t, test.expectedTraceId, traceparent.TraceId)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/model/servicemode"
+)
+
+const (
+	sysPathPrefix = "/sys"
+	// defaultServiceModeRetryAfter is sent when the mode doesn't say how long it lasts
+	defaultServiceModeRetryAfter = 60
+)
+
+// ServiceModeProvider returns the current service mode, e.g. the
+// ServiceModeStore of the repositories package.
+type ServiceModeProvider interface {
+	Mode() servicemode.Mode
+}
+
+// ServiceMode rejects requests while the service is in maintenance, or
+// mutating requests while it's read-only, with a 503 and Retry-After. /sys
+// routes are left alone so probes keep working and the mode can be switched back.
+func ServiceMode(provider ServiceModeProvider) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		path := ctx.Request.URL.Path
+		if path == sysPathPrefix || strings.HasPrefix(path, sysPathPrefix+"/") {
+			ctx.Next()
+			return
+		}
+
+		mode := provider.Mode()
+		var err *errors.CustomError
+		switch {
+		case mode.Maintenance:
+			err = errors.NewServiceUnavailableError("MAINTENANCE_MODE", fmt.Errorf("The service is under maintenance."))
+		case mode.ReadOnly && !isSafeMethod(ctx.Request.Method):
+			err = errors.NewServiceUnavailableError("READ_ONLY_MODE", fmt.Errorf("The service is read-only, changes aren't accepted."))
+		default:
+			ctx.Next()
+			return
+		}
+
+		if mode.Reason != nil {
+			err.Message = fmt.Sprintf("%s %s", err.Message, *mode.Reason)
+		}
+		retryAfter := mode.RetryAfterSeconds
+		if retryAfter <= 0 {
+			retryAfter = defaultServiceModeRetryAfter
+		}
+		ctx.Header(RetryAfterHeader, strconv.Itoa(retryAfter))
+		abortWithError(ctx, err)
+	}
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,52 @@
+//go:build unit
+
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/middlewares"
+	"github.com/ginerator/base/model/servicemode"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticServiceMode servicemode.Mode
+
+func (mode staticServiceMode) Mode() servicemode.Mode {
+	return servicemode.Mode(mode)
+}
+
+func TestServiceMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       servicemode.Mode
+		method     string
+		path       string
+		expected   int
+		retryAfter string
+	}{
+		{"normal", servicemode.Mode{}, http.MethodPost, "/rooms", http.StatusOK, ""},
+		{"maintenance", servicemode.Mode{Maintenance: true, RetryAfterSeconds: 120}, http.MethodGet, "/rooms", http.StatusServiceUnavailable, "120"},
+		{"maintenance sys", servicemode.Mode{Maintenance: true}, http.MethodGet, "/sys/health", http.StatusOK, ""},
+		{"read-only read", servicemode.Mode{ReadOnly: true}, http.MethodGet, "/rooms", http.StatusOK, ""},
+		{"read-only write", servicemode.Mode{ReadOnly: true}, http.MethodPost, "/rooms", http.StatusServiceUnavailable, "60"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(middlewares.ServiceMode(staticServiceMode(test.mode)))
+			router.Any("/rooms", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+			router.GET("/sys/health", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(test.method, test.path, nil))
+			assert.Equal(t, test.expected, response.Code)
+			assert.Equal(t, test.retryAfter, response.Header().Get(middlewares.RetryAfterHeader))
+		})
+	}
+}
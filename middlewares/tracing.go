@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	user "github.com/ginerator/base/model/users"
+	"github.com/ginerator/base/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/ginerator/base/middlewares"
+
+// Tracing starts a server span per request, named by its route template and
+// child of the W3C trace context of the request if it has one. The span is
+// stored in the context of the request, so repository queries traced by
+// bunotel become its children. Register it after RequestId, so logs and
+// outbound calls use the ids of the span.
+func Tracing() gin.HandlerFunc {
+	tracer := otel.Tracer(tracerName)
+
+	return func(ctx *gin.Context) {
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		requestCtx := otel.GetTextMapPropagator().Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+		spanCtx, span := tracer.Start(requestCtx, ctx.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(ctx.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(ctx.Request.URL.Path),
+				semconv.ClientAddress(ctx.ClientIP()),
+				semconv.UserAgentOriginal(ctx.Request.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		if spanContext := span.SpanContext(); spanContext.IsValid() {
+			traceparent := utils.Traceparent{
+				TraceId:  spanContext.TraceID().String(),
+				ParentId: spanContext.SpanID().String(),
+				Flags:    spanContext.TraceFlags().String(),
+			}
+			ctx.Set(utils.TraceparentTag, traceparent)
+			spanCtx = context.WithValue(spanCtx, utils.TraceparentTag, traceparent)
+			ctx.Header(utils.TraceparentHeader, traceparent.String())
+		}
+		ctx.Request = ctx.Request.WithContext(spanCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if contextUser := user.GetUser(ctx); contextUser != nil {
+			span.SetAttributes(attribute.String("user.type", string(contextUser.Type)))
+			if actor := contextUser.Actor(); actor != nil {
+				span.SetAttributes(semconv.EnduserID(*actor))
+			}
+		}
+		if code := errorCode(ctx); code != "" {
+			span.SetAttributes(attribute.String("error.code", code))
+		}
+		for _, ginError := range ctx.Errors {
+			span.RecordError(ginError.Err)
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
@@ -0,0 +1,50 @@
+//go:build unit
+
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/middlewares"
+	"github.com/ginerator/base/utils"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.RequestId(), middlewares.Tracing())
+	router.GET("/rooms/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusServiceUnavailable)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/rooms/1", nil)
+	request.Header.Set(utils.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		span := spans[0]
+		assert.Equal(t, "GET /rooms/:id", span.Name())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+		assert.Equal(t, codes.Error, span.Status().Code)
+
+		traceparent, ok := utils.ParseTraceparent(response.Header().Get(utils.TraceparentHeader))
+		assert.True(t, ok)
+		assert.Equal(t, span.SpanContext().SpanID().String(), traceparent.ParentId)
+	}
+}
@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type AuditLogEntry struct {
+	bun.BaseModel `bun:"table:audit_log"`
+
+	Id         int64           `bun:"id,pk,autoincrement" json:"id"`
+	EntityType string          `bun:"entity_type,notnull" json:"entityType"`
+	EntityId   string          `bun:"entity_id,notnull" json:"entityId"`
+	Action     string          `bun:"action,notnull" json:"action"`
+	Before     json.RawMessage `bun:"before,type:jsonb" json:"before"`
+	After      json.RawMessage `bun:"after,type:jsonb" json:"after"`
+	Changes    json.RawMessage `bun:"changes,type:jsonb" json:"changes"`
+	ActorId    *string         `bun:"actor_id" json:"actorId"`
+	ActorType  *string         `bun:"actor_type" json:"actorType"`
+	RequestId  *string         `bun:"request_id" json:"requestId"`
+	CreatedAt  time.Time       `bun:"created_at,notnull,default:current_timestamp" json:"createdAt"`
+}
+
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
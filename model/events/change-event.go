@@ -0,0 +1,22 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ChangeEvent is a repository write published on the change feed. Events are
+// kept in the change_events table so clients can replay what they missed.
+type ChangeEvent struct {
+	bun.BaseModel `bun:"table:change_events"`
+
+	Id         int64           `bun:"id,pk,autoincrement" json:"id"`
+	EntityType string          `bun:"entity_type,notnull" json:"entityType"`
+	EntityId   string          `bun:"entity_id,notnull" json:"entityId"`
+	Action     string          `bun:"action,notnull" json:"action"`
+	UserId     *string         `bun:"user_id" json:"-"`
+	Payload    json.RawMessage `bun:"payload,type:jsonb" json:"payload"`
+	CreatedAt  time.Time       `bun:"created_at,notnull,default:current_timestamp" json:"createdAt"`
+}
@@ -0,0 +1,37 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// HeaderUserId is the header carrying the owner of the aggregate, when it has one.
+const HeaderUserId = "userId"
+
+// Event is a domain event to be delivered through the outbox.
+type Event struct {
+	Type          string            `json:"type"`
+	AggregateType string            `json:"aggregateType"`
+	AggregateId   string            `json:"aggregateId"`
+	Payload       interface{}       `json:"payload"`
+	Headers       map[string]string `json:"headers,omitempty"`
+}
+
+type OutboxMessage struct {
+	bun.BaseModel `bun:"table:outbox"`
+
+	Id            int64             `bun:"id,pk,autoincrement" json:"id"`
+	EventType     string            `bun:"event_type,notnull" json:"eventType"`
+	AggregateType string            `bun:"aggregate_type,notnull" json:"aggregateType"`
+	AggregateId   string            `bun:"aggregate_id,notnull" json:"aggregateId"`
+	Payload       json.RawMessage   `bun:"payload,type:jsonb" json:"payload"`
+	Headers       map[string]string `bun:"headers,type:jsonb" json:"headers,omitempty"`
+	Attempts      int               `bun:"attempts,notnull,default:0" json:"attempts"`
+	NextAttemptAt time.Time         `bun:"next_attempt_at,notnull,default:current_timestamp" json:"nextAttemptAt"`
+	LastError     *string           `bun:"last_error" json:"lastError,omitempty"`
+	SentAt        *time.Time        `bun:"sent_at" json:"sentAt,omitempty"`
+	FailedAt      *time.Time        `bun:"failed_at" json:"failedAt,omitempty"`
+	CreatedAt     time.Time         `bun:"created_at,notnull,default:current_timestamp" json:"createdAt"`
+}
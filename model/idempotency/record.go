@@ -0,0 +1,27 @@
+package idempotency
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Record is the response stored for an Idempotency-Key. Its status is 0
+// while the original request is still in flight.
+type Record struct {
+	bun.BaseModel `bun:"table:idempotency_keys"`
+
+	Scope       string    `bun:"scope,pk" json:"scope"`
+	Key         string    `bun:"key,pk" json:"key"`
+	RequestHash string    `bun:"request_hash,notnull" json:"requestHash"`
+	Status      int       `bun:"status,notnull,default:0" json:"status"`
+	ContentType *string   `bun:"content_type" json:"contentType,omitempty"`
+	Body        []byte    `bun:"body,type:bytea" json:"-"`
+	LockedUntil time.Time `bun:"locked_until,notnull" json:"lockedUntil"`
+	ExpiresAt   time.Time `bun:"expires_at,notnull" json:"expiresAt"`
+	CreatedAt   time.Time `bun:"created_at,notnull,default:current_timestamp" json:"createdAt"`
+}
+
+func (record Record) Completed() bool {
+	return record.Status != 0
+}
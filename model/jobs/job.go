@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "PENDING"
+	JobStatusRunning   JobStatus = "RUNNING"
+	JobStatusSucceeded JobStatus = "SUCCEEDED"
+	JobStatusDead      JobStatus = "DEAD"
+)
+
+type Job struct {
+	bun.BaseModel `bun:"table:jobs"`
+
+	Id          int64           `bun:"id,pk,autoincrement" json:"id"`
+	Queue       string          `bun:"queue,notnull" json:"queue"`
+	Kind        string          `bun:"kind,notnull" json:"kind"`
+	Payload     json.RawMessage `bun:"payload,type:jsonb" json:"payload"`
+	Status      JobStatus       `bun:"status,notnull" json:"status"`
+	Attempts    int             `bun:"attempts,notnull,default:0" json:"attempts"`
+	MaxAttempts int             `bun:"max_attempts,notnull" json:"maxAttempts"`
+	RunAt       time.Time       `bun:"run_at,notnull,default:current_timestamp" json:"runAt"`
+	UniqueKey   *string         `bun:"unique_key" json:"uniqueKey,omitempty"`
+	LastError   *string         `bun:"last_error" json:"lastError,omitempty"`
+	LockedAt    *time.Time      `bun:"locked_at" json:"lockedAt,omitempty"`
+	FinishedAt  *time.Time      `bun:"finished_at" json:"finishedAt,omitempty"`
+	CreatedAt   time.Time       `bun:"created_at,notnull,default:current_timestamp" json:"createdAt"`
+}
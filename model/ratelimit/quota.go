@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Quota is a token bucket: Limit requests in a burst, refilled at Limit per Period.
+type Quota struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Rate is how many tokens are refilled per second.
+func (quota Quota) Rate() float64 {
+	return float64(quota.Limit) / quota.Period.Seconds()
+}
+
+// Refill returns the tokens of a bucket after elapsed, capped at Limit.
+func (quota Quota) Refill(tokens float64, elapsed time.Duration) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(quota.Limit), tokens+elapsed.Seconds()*quota.Rate())
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a request is allowed again, when it wasn't
+	RetryAfter time.Duration
+}
+
+// NewResult describes a bucket left with tokens after a request.
+func NewResult(quota Quota, tokens float64, allowed bool) Result {
+	result := Result{
+		Allowed:   allowed,
+		Limit:     quota.Limit,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     secondsToDuration((float64(quota.Limit) - tokens) / quota.Rate()),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / quota.Rate())
+	}
+	return result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Max(0, seconds) * float64(time.Second))
+}
+
+// Bucket is the state of a token bucket shared by every replica.
+type Bucket struct {
+	bun.BaseModel `bun:"table:rate_limit_buckets"`
+
+	Key       string    `bun:"key,pk"`
+	Tokens    float64   `bun:"tokens,notnull"`
+	Allowed   bool      `bun:"allowed,notnull"`
+	UpdatedAt time.Time `bun:"updated_at,notnull"`
+}
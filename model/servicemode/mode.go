@@ -0,0 +1,32 @@
+package servicemode
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// SingletonId is the id of the only row of service_modes, shared by every replica.
+const SingletonId = 1
+
+// Mode holds the switches taking the service out of normal operation. In
+// maintenance every route but /sys answers 503, in read-only mode only
+// requests with a mutating method do.
+type Mode struct {
+	bun.BaseModel `bun:"table:service_modes"`
+
+	Id                int       `bun:"id,pk" json:"-"`
+	Maintenance       bool      `bun:"maintenance,notnull,default:false" json:"maintenance"`
+	ReadOnly          bool      `bun:"read_only,notnull,default:false" json:"readOnly"`
+	Reason            *string   `bun:"reason" json:"reason,omitempty"`
+	RetryAfterSeconds int       `bun:"retry_after_seconds,notnull,default:0" json:"retryAfterSeconds"`
+	UpdatedBy         *string   `bun:"updated_by" json:"updatedBy,omitempty"`
+	UpdatedAt         time.Time `bun:"updated_at,notnull,default:current_timestamp" json:"updatedAt"`
+}
+
+type UpdateModeRequest struct {
+	Maintenance       *bool   `json:"maintenance"`
+	ReadOnly          *bool   `json:"readOnly"`
+	Reason            *string `json:"reason" validate:"omitempty,max=500"`
+	RetryAfterSeconds *int    `json:"retryAfterSeconds" validate:"omitempty,min=0"`
+}
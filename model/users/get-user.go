@@ -0,0 +1,22 @@
+package user
+
+import "github.com/gin-gonic/gin"
+
+// GetUser returns the user the authentication middleware stored in the context.
+func GetUser(ctx *gin.Context) *User {
+	if value, exists := ctx.Get(ContextTagUser); exists {
+		if contextUser, ok := value.(User); ok {
+			return &contextUser
+		}
+	}
+	return nil
+}
+
+// Actor identifies who performed an action: the account id of a PERSON or
+// the client name of a SYSTEM.
+func (u User) Actor() *string {
+	if u.Type == UserTypeSystem {
+		return u.Source
+	}
+	return u.Id
+}
@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "PENDING"
+	DeliveryStatusDelivered DeliveryStatus = "DELIVERED"
+	DeliveryStatusFailed    DeliveryStatus = "FAILED"
+	DeliveryStatusCancelled DeliveryStatus = "CANCELLED"
+)
+
+// Delivery is an event sent, or being sent, to a subscription.
+type Delivery struct {
+	bun.BaseModel `bun:"table:webhook_deliveries"`
+
+	Id             uuid.UUID       `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	SubscriptionId uuid.UUID       `bun:"subscription_id,type:uuid,notnull" json:"subscriptionId"`
+	EventId        string          `bun:"event_id,notnull" json:"eventId"`
+	EventType      string          `bun:"event_type,notnull" json:"eventType"`
+	Payload        json.RawMessage `bun:"payload,type:jsonb" json:"payload"`
+	Status         DeliveryStatus  `bun:"status,notnull" json:"status"`
+	Attempts       int             `bun:"attempts,notnull,default:0" json:"attempts"`
+	ResponseStatus *int            `bun:"response_status" json:"responseStatus,omitempty"`
+	ResponseBody   *string         `bun:"response_body" json:"responseBody,omitempty"`
+	LastError      *string         `bun:"last_error" json:"lastError,omitempty"`
+	DurationMs     int64           `bun:"duration_ms,notnull,default:0" json:"durationMs"`
+	DeliveredAt    *time.Time      `bun:"delivered_at" json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time       `bun:"created_at,notnull,default:current_timestamp" json:"createdAt"`
+	UpdatedAt      time.Time       `bun:"updated_at,notnull,default:current_timestamp" json:"updatedAt"`
+}
+
+type DeliveryQuery struct {
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+	SortBy string `form:"sortBy"`
+	Sort   string `form:"sort"`
+}
@@ -0,0 +1,56 @@
+package webhooks
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// EventTypeAll subscribes to every event type.
+const EventTypeAll = "*"
+
+type Subscription struct {
+	bun.BaseModel `bun:"table:webhook_subscriptions"`
+
+	Id             uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	UserId         string     `bun:"userid,notnull" json:"userId"`
+	Url            string     `bun:"url,notnull" json:"url"`
+	EventTypes     []string   `bun:"event_types,array,notnull" json:"eventTypes"`
+	Secret         string     `bun:"secret,notnull" json:"-"`
+	Active         bool       `bun:"active,notnull,default:true" json:"active"`
+	Failures       int        `bun:"failures,notnull,default:0" json:"consecutiveFailures"`
+	DisabledAt     *time.Time `bun:"disabled_at" json:"disabledAt,omitempty"`
+	DisabledReason *string    `bun:"disabled_reason" json:"disabledReason,omitempty"`
+	CreatedAt      time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"createdAt"`
+	UpdatedAt      time.Time  `bun:"updated_at,notnull,default:current_timestamp" json:"updatedAt"`
+	DeletedAt      *time.Time `bun:"deleted_at" json:"deletedAt,omitempty"`
+}
+
+// CreatedSubscription is only returned on creation, the one time the signing
+// secret is shown.
+type CreatedSubscription struct {
+	Subscription
+	Secret string `json:"secret"`
+}
+
+type CreateSubscriptionRequest struct {
+	Url        string   `json:"url" validate:"required,url"`
+	EventTypes []string `json:"eventTypes" validate:"required,min=1,dive,required"`
+}
+
+type UpdateSubscriptionRequest struct {
+	bun.BaseModel `bun:"table:webhook_subscriptions"`
+
+	Url        *string  `bun:"url" json:"url" validate:"omitempty,url"`
+	EventTypes []string `bun:"event_types,array" json:"eventTypes" validate:"omitempty,min=1,dive,required"`
+	Active     *bool    `bun:"active" json:"active"`
+}
+
+type SubscriptionQuery struct {
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+	SortBy string `form:"sortBy"`
+	Sort   string `form:"sort"`
+	Active *bool  `form:"active"`
+}
@@ -0,0 +1,122 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/events"
+	"github.com/ginerator/base/utils"
+)
+
+// Publisher delivers outbox messages. Returning an error makes the relay
+// retry the message later.
+type Publisher interface {
+	Publish(ctx context.Context, message events.OutboxMessage) error
+}
+
+type Handler func(ctx context.Context, message events.OutboxMessage) error
+
+// InProcessPublisher hands the messages to handlers registered by event type
+// in the same process. Handlers registered for "*" receive every message.
+type InProcessPublisher struct {
+	handlers map[string][]Handler
+	mutex    sync.RWMutex
+}
+
+func NewInProcessPublisher() *InProcessPublisher {
+	return &InProcessPublisher{
+		handlers: make(map[string][]Handler),
+	}
+}
+
+func (publisher *InProcessPublisher) Subscribe(eventType string, handler Handler) {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+	publisher.handlers[eventType] = append(publisher.handlers[eventType], handler)
+}
+
+func (publisher *InProcessPublisher) Publish(ctx context.Context, message events.OutboxMessage) error {
+	publisher.mutex.RLock()
+	handlers := append(append([]Handler{}, publisher.handlers[message.EventType]...), publisher.handlers["*"]...)
+	publisher.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WebhookPublisher POSTs every message as JSON to a single URL. Any non 2xx
+// response is treated as a failed delivery.
+type WebhookPublisher struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewWebhookPublisher(url string, headers map[string]string, timeout time.Duration) *WebhookPublisher {
+	return &WebhookPublisher{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout, Transport: utils.NewCorrelationTransport(nil)},
+	}
+}
+
+func (publisher *WebhookPublisher) Publish(ctx context.Context, message events.OutboxMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, publisher.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Event-Type", message.EventType)
+	request.Header.Set("X-Event-Id", fmt.Sprint(message.Id))
+	for header, value := range publisher.headers {
+		request.Header.Set(header, value)
+	}
+	for header, value := range message.Headers {
+		request.Header.Set(header, value)
+	}
+
+	response, err := publisher.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("Webhook %s answered with status %d.", publisher.url, response.StatusCode)
+	}
+	return nil
+}
+
+// LogPublisher only logs the messages. It stands in for a real publisher in
+// development or until one is available.
+type LogPublisher struct{}
+
+func NewLogPublisher() *LogPublisher {
+	return &LogPublisher{}
+}
+
+func (publisher *LogPublisher) Publish(ctx context.Context, message events.OutboxMessage) error {
+	logger.For(ctx, logComponent).Info().
+		Int64("id", message.Id).
+		Str("eventType", message.EventType).
+		Str("aggregateType", message.AggregateType).
+		Str("aggregateId", message.AggregateId).
+		RawJSON("payload", message.Payload).
+		Msg("[OUTBOX] - LogPublisher - Event published")
+	return nil
+}
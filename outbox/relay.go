@@ -0,0 +1,184 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/events"
+	postgres "github.com/ginerator/base/repositories"
+	"github.com/ginerator/base/utils"
+	"github.com/uptrace/bun"
+)
+
+const logComponent = "outbox"
+
+type RelayOptions struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+func DefaultRelayOptions() RelayOptions {
+	return RelayOptions{
+		PollInterval: time.Second,
+		BatchSize:    100,
+		MaxAttempts:  10,
+		BaseBackoff:  time.Second,
+		MaxBackoff:   10 * time.Minute,
+	}
+}
+
+// Relay polls the outbox table and delivers pending messages through a
+// Publisher. Rows are claimed with FOR UPDATE SKIP LOCKED, so several
+// replicas can relay at the same time without sending a message twice.
+type Relay struct {
+	client    *postgres.BunPostgresDatabaseClient
+	publisher Publisher
+	options   RelayOptions
+
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+func NewRelay(client *postgres.BunPostgresDatabaseClient, publisher Publisher, options RelayOptions) *Relay {
+	defaults := DefaultRelayOptions()
+	if options.PollInterval <= 0 {
+		options.PollInterval = defaults.PollInterval
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaults.BatchSize
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = defaults.MaxAttempts
+	}
+	if options.BaseBackoff <= 0 {
+		options.BaseBackoff = defaults.BaseBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaults.MaxBackoff
+	}
+
+	logger.Component(logComponent).Info().Msg("Outbox relay initialized.")
+	return &Relay{
+		client:    client,
+		publisher: publisher,
+		options:   options,
+	}
+}
+
+// Start runs the relay in the background until Close is called.
+func (relay *Relay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	relay.cancel = cancel
+
+	relay.done.Add(1)
+	go func() {
+		defer relay.done.Done()
+
+		ticker := time.NewTicker(relay.options.PollInterval)
+		defer ticker.Stop()
+		for {
+			// Keep draining while full batches come back
+			for {
+				relayed, err := relay.RelayBatch(ctx)
+				if err != nil || relayed < relay.options.BatchSize {
+					break
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close stops polling and waits for the batch in flight.
+func (relay *Relay) Close() {
+	if relay.cancel == nil {
+		return
+	}
+	relay.cancel()
+	relay.done.Wait()
+	logger.Component(logComponent).Info().Msg("Outbox relay stopped.")
+}
+
+// RelayBatch delivers one batch of pending messages and returns how many were claimed.
+func (relay *Relay) RelayBatch(ctx context.Context) (int, error) {
+	claimed := 0
+	err := relay.client.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		messages := make([]events.OutboxMessage, 0)
+		err := tx.NewSelect().
+			Model(&messages).
+			Where("sent_at IS NULL").
+			Where("failed_at IS NULL").
+			Where("next_attempt_at <= CURRENT_TIMESTAMP").
+			OrderExpr("id ASC").
+			Limit(relay.options.BatchSize).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+		claimed = len(messages)
+
+		for _, message := range messages {
+			if err := relay.deliver(ctx, tx, message); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && err != context.Canceled {
+		logger.Component(logComponent).Error().Err(err).Msg("[OUTBOX] - RelayBatch - Error relaying messages")
+	}
+	return claimed, err
+}
+
+func (relay *Relay) deliver(ctx context.Context, tx bun.Tx, message events.OutboxMessage) error {
+	publishErr := relay.publisher.Publish(ctx, message)
+	if publishErr == nil {
+		_, err := tx.NewUpdate().
+			Model(&message).
+			Set("sent_at = CURRENT_TIMESTAMP").
+			Set("attempts = attempts + 1").
+			WherePK().
+			Exec(ctx)
+		return err
+	}
+
+	attempts := message.Attempts + 1
+	lastError := publishErr.Error()
+	query := tx.NewUpdate().
+		Model(&message).
+		Set("attempts = ?", attempts).
+		Set("last_error = ?", lastError).
+		WherePK()
+
+	if attempts >= relay.options.MaxAttempts {
+		logger.Component(logComponent).Error().
+			Err(publishErr).
+			Int64("id", message.Id).
+			Str("eventType", message.EventType).
+			Msg("[OUTBOX] - deliver - Giving up on message")
+		query.Set("failed_at = CURRENT_TIMESTAMP")
+	} else {
+		backoff := utils.ExponentialBackoff(attempts, relay.options.BaseBackoff, relay.options.MaxBackoff)
+		logger.Component(logComponent).Warn().
+			Err(publishErr).
+			Int64("id", message.Id).
+			Str("eventType", message.EventType).
+			Dur("retryIn", backoff).
+			Msg("[OUTBOX] - deliver - Error publishing message")
+		query.Set("next_attempt_at = ?", time.Now().Add(backoff))
+	}
+
+	_, err := query.Exec(ctx)
+	return err
+}
@@ -0,0 +1,237 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/jobs"
+	postgres "github.com/ginerator/base/repositories"
+)
+
+const (
+	DefaultQueue = "default"
+
+	logComponent = "queue"
+)
+
+type Options struct {
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// JobTimeout bounds a single run of a handler
+	JobTimeout time.Duration
+	// StaleAfter is how long a job may stay RUNNING before it's considered
+	// abandoned by a crashed worker and retried. It must exceed JobTimeout.
+	StaleAfter time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		PollInterval: time.Second,
+		MaxAttempts:  5,
+		BaseBackoff:  5 * time.Second,
+		MaxBackoff:   time.Hour,
+		JobTimeout:   5 * time.Minute,
+		StaleAfter:   15 * time.Minute,
+	}
+}
+
+type handler func(ctx context.Context, payload json.RawMessage) error
+
+// Queue is a background job queue stored in the jobs table. Jobs are claimed
+// with SELECT ... FOR UPDATE SKIP LOCKED, so any number of replicas can work
+// the same queues.
+type Queue struct {
+	client  *postgres.BunPostgresDatabaseClient
+	options Options
+
+	handlers    map[string]handler
+	concurrency map[string]int
+	mutex       sync.RWMutex
+
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+func NewQueue(client *postgres.BunPostgresDatabaseClient, options Options) *Queue {
+	defaults := DefaultOptions()
+	if options.PollInterval <= 0 {
+		options.PollInterval = defaults.PollInterval
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = defaults.MaxAttempts
+	}
+	if options.BaseBackoff <= 0 {
+		options.BaseBackoff = defaults.BaseBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaults.MaxBackoff
+	}
+	if options.JobTimeout <= 0 {
+		options.JobTimeout = defaults.JobTimeout
+	}
+	if options.StaleAfter <= 0 {
+		options.StaleAfter = defaults.StaleAfter
+	}
+
+	logger.Component(logComponent).Info().Msg("Job queue initialized.")
+	return &Queue{
+		client:      client,
+		options:     options,
+		handlers:    make(map[string]handler),
+		concurrency: map[string]int{DefaultQueue: 1},
+	}
+}
+
+// Register sets the handler of a job kind. The JSON payload of the job is
+// decoded into T before calling it.
+func Register[T interface{}](queue *Queue, kind string, handlerFunction func(ctx context.Context, payload T) error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	queue.handlers[kind] = func(ctx context.Context, rawPayload json.RawMessage) error {
+		var payload T
+		if err := json.Unmarshal(rawPayload, &payload); err != nil {
+			return fmt.Errorf("Invalid payload for job kind %s: %w", kind, err)
+		}
+		return handlerFunction(ctx, payload)
+	}
+}
+
+// SetConcurrency sets how many jobs of a queue this process runs at the same
+// time. Only queues with a concurrency are worked, "default" starts with 1.
+func (queue *Queue) SetConcurrency(queueName string, concurrency int) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.concurrency[queueName] = concurrency
+}
+
+type enqueueOptions struct {
+	queue       string
+	runAt       *time.Time
+	maxAttempts int
+	uniqueKey   *string
+}
+
+type EnqueueOption func(*enqueueOptions)
+
+func WithQueue(queueName string) EnqueueOption {
+	return func(options *enqueueOptions) {
+		options.queue = queueName
+	}
+}
+
+func WithRunAt(runAt time.Time) EnqueueOption {
+	return func(options *enqueueOptions) {
+		options.runAt = &runAt
+	}
+}
+
+func WithDelay(delay time.Duration) EnqueueOption {
+	return WithRunAt(time.Now().Add(delay))
+}
+
+func WithMaxAttempts(maxAttempts int) EnqueueOption {
+	return func(options *enqueueOptions) {
+		options.maxAttempts = maxAttempts
+	}
+}
+
+// WithUniqueKey makes the job unique while it's pending or running: enqueuing
+// the same key again returns the existing job instead of a new one.
+func WithUniqueKey(uniqueKey string) EnqueueOption {
+	return func(options *enqueueOptions) {
+		options.uniqueKey = &uniqueKey
+	}
+}
+
+// Enqueue stores a job. Called with the context of a transaction, the job
+// is only enqueued if the transaction commits.
+func (queue *Queue) Enqueue(ctx context.Context, kind string, payload interface{}, opts ...EnqueueOption) (jobs.Job, error) {
+	options := enqueueOptions{
+		queue:       DefaultQueue,
+		maxAttempts: queue.options.MaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+
+	job := jobs.Job{
+		Queue:       options.queue,
+		Kind:        kind,
+		Payload:     rawPayload,
+		Status:      jobs.JobStatusPending,
+		MaxAttempts: options.maxAttempts,
+		UniqueKey:   options.uniqueKey,
+	}
+
+	db := queue.client.GetDB(ctx)
+	query := db.NewInsert().Model(&job).Returning("*")
+	if options.runAt != nil {
+		query.Value("run_at", "?", *options.runAt)
+	}
+	if options.uniqueKey != nil {
+		query.On("CONFLICT (unique_key) WHERE status IN (?, ?) DO NOTHING", jobs.JobStatusPending, jobs.JobStatusRunning)
+	}
+
+	err = query.Scan(ctx, &job)
+	if err == sql.ErrNoRows && options.uniqueKey != nil {
+		err = db.NewSelect().
+			Model(&job).
+			Where("unique_key = ?", *options.uniqueKey).
+			Where("status IN (?, ?)", jobs.JobStatusPending, jobs.JobStatusRunning).
+			Limit(1).
+			Scan(ctx)
+	}
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("kind", kind).Str("queue", options.queue).Msg("[JOB QUEUE] - Enqueue - Error enqueuing job")
+		return job, err
+	}
+	return job, nil
+}
+
+// CreateJobsTable creates the jobs table and its indexes if they don't exist yet.
+func (queue *Queue) CreateJobsTable(ctx context.Context) error {
+	db := queue.client.DB
+	_, err := db.NewCreateTable().Model((*jobs.Job)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[JOB QUEUE] - CreateJobsTable - Error creating table")
+		return err
+	}
+
+	_, err = db.NewCreateIndex().
+		Model((*jobs.Job)(nil)).
+		Index("jobs_claim_idx").
+		IfNotExists().
+		Column("queue", "run_at").
+		Where("status = ?", jobs.JobStatusPending).
+		Exec(ctx)
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[JOB QUEUE] - CreateJobsTable - Error creating claim index")
+		return err
+	}
+
+	_, err = db.NewCreateIndex().
+		Model((*jobs.Job)(nil)).
+		Index("jobs_unique_key_idx").
+		Unique().
+		IfNotExists().
+		Column("unique_key").
+		Where("status IN (?, ?)", jobs.JobStatusPending, jobs.JobStatusRunning).
+		Exec(ctx)
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[JOB QUEUE] - CreateJobsTable - Error creating unique index")
+	}
+	return err
+}
@@ -0,0 +1,235 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/jobs"
+	"github.com/ginerator/base/utils"
+)
+
+// Start runs the workers of every queue with a concurrency until Close is called.
+func (queue *Queue) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	queue.cancel = cancel
+
+	queue.mutex.RLock()
+	defer queue.mutex.RUnlock()
+
+	for queueName, concurrency := range queue.concurrency {
+		for i := 0; i < concurrency; i++ {
+			queue.done.Add(1)
+			go queue.work(ctx, queueName)
+		}
+	}
+
+	queue.done.Add(1)
+	go queue.rescueStaleJobs(ctx)
+	logger.Component(logComponent).Info().Msg("Job queue workers started.")
+}
+
+// Close stops claiming new jobs and waits for the running ones to finish.
+func (queue *Queue) Close() {
+	if queue.cancel == nil {
+		return
+	}
+	queue.cancel()
+	queue.done.Wait()
+	logger.Component(logComponent).Info().Msg("Job queue workers stopped.")
+}
+
+func (queue *Queue) work(ctx context.Context, queueName string) {
+	defer queue.done.Done()
+
+	for {
+		job, err := queue.claim(ctx, queueName)
+		if err != nil && ctx.Err() == nil {
+			logger.Component(logComponent).Error().Err(err).Str("queue", queueName).Msg("[JOB QUEUE] - work - Error claiming job")
+		}
+
+		if job != nil {
+			// Jobs get their own context so shutting down lets them finish
+			jobCtx, cancel := context.WithTimeout(context.Background(), queue.options.JobTimeout)
+			queue.run(jobCtx, *job)
+			cancel()
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(queue.options.PollInterval):
+		}
+	}
+}
+
+func (queue *Queue) claim(ctx context.Context, queueName string) (*jobs.Job, error) {
+	job := new(jobs.Job)
+	err := queue.client.DB.NewRaw(
+		`UPDATE jobs SET status = ?, locked_at = CURRENT_TIMESTAMP, attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE queue = ? AND status = ? AND run_at <= CURRENT_TIMESTAMP
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		jobs.JobStatusRunning, queueName, jobs.JobStatusPending,
+	).Scan(ctx, job)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (queue *Queue) run(ctx context.Context, job jobs.Job) {
+	queue.mutex.RLock()
+	handlerFunction, exists := queue.handlers[job.Kind]
+	queue.mutex.RUnlock()
+
+	var err error
+	if !exists {
+		err = fmt.Errorf("No handler registered for job kind %s.", job.Kind)
+		// Retrying won't help until a handler is deployed
+		job.Attempts = job.MaxAttempts
+	} else {
+		err = runHandler(ctx, handlerFunction, job)
+	}
+
+	if err := queue.finish(ctx, job, err); err != nil {
+		logger.Component(logComponent).Error().Err(err).Int64("id", job.Id).Msg("[JOB QUEUE] - run - Error saving job result")
+	}
+}
+
+func runHandler(ctx context.Context, handlerFunction handler, job jobs.Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("Job panicked: %v", recovered)
+		}
+	}()
+	return handlerFunction(ctx, job.Payload)
+}
+
+// nextStatus decides what becomes of a job once run: it succeeded, it's
+// retried after a backoff or, out of attempts, it's moved to the dead letter.
+func (queue *Queue) nextStatus(job jobs.Job, jobErr error) (jobs.JobStatus, time.Duration) {
+	switch {
+	case jobErr == nil:
+		return jobs.JobStatusSucceeded, 0
+	case job.Attempts >= job.MaxAttempts:
+		return jobs.JobStatusDead, 0
+	default:
+		return jobs.JobStatusPending, utils.ExponentialBackoff(job.Attempts, queue.options.BaseBackoff, queue.options.MaxBackoff)
+	}
+}
+
+func (queue *Queue) finish(ctx context.Context, job jobs.Job, jobErr error) error {
+	// The job context may be expired by now
+	ctx = context.Background()
+
+	query := queue.client.DB.NewUpdate().Model(&job).Set("locked_at = NULL").WherePK()
+	status, backoff := queue.nextStatus(job, jobErr)
+	switch status {
+	case jobs.JobStatusSucceeded:
+		query.
+			Set("status = ?", status).
+			Set("finished_at = CURRENT_TIMESTAMP").
+			Set("last_error = NULL")
+	case jobs.JobStatusDead:
+		logger.Component(logComponent).Error().
+			Err(jobErr).
+			Int64("id", job.Id).
+			Str("kind", job.Kind).
+			Int("attempts", job.Attempts).
+			Msg("[JOB QUEUE] - finish - Job moved to dead letter")
+		query.
+			Set("status = ?", status).
+			Set("finished_at = CURRENT_TIMESTAMP").
+			Set("last_error = ?", jobErr.Error())
+	default:
+		logger.Component(logComponent).Warn().
+			Err(jobErr).
+			Int64("id", job.Id).
+			Str("kind", job.Kind).
+			Dur("retryIn", backoff).
+			Msg("[JOB QUEUE] - finish - Job failed")
+		query.
+			Set("status = ?", status).
+			Set("run_at = ?", time.Now().Add(backoff)).
+			Set("last_error = ?", jobErr.Error())
+	}
+
+	_, err := query.Exec(ctx)
+	return err
+}
+
+// rescueStaleJobs puts back jobs left RUNNING by workers that died.
+func (queue *Queue) rescueStaleJobs(ctx context.Context) {
+	defer queue.done.Done()
+
+	ticker := time.NewTicker(queue.options.StaleAfter / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		result, err := queue.client.DB.NewRaw(
+			`UPDATE jobs SET
+				status = CASE WHEN attempts >= max_attempts THEN ? ELSE ? END,
+				finished_at = CASE WHEN attempts >= max_attempts THEN CURRENT_TIMESTAMP END,
+				last_error = 'Job abandoned by its worker.',
+				locked_at = NULL
+			WHERE status = ? AND locked_at < ?`,
+			jobs.JobStatusDead, jobs.JobStatusPending, jobs.JobStatusRunning, time.Now().Add(-queue.options.StaleAfter),
+		).Exec(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Component(logComponent).Error().Err(err).Msg("[JOB QUEUE] - rescueStaleJobs - Error rescuing jobs")
+			}
+			continue
+		}
+		if rescued, _ := result.RowsAffected(); rescued > 0 {
+			logger.Component(logComponent).Warn().Int64("jobs", rescued).Msg("[JOB QUEUE] - rescueStaleJobs - Stale jobs rescued")
+		}
+	}
+}
+
+type queueDepth struct {
+	Queue  string         `bun:"queue"`
+	Status jobs.JobStatus `bun:"status"`
+	Count  int            `bun:"count"`
+}
+
+// Report returns the number of pending, running and dead jobs by queue, for /sys/health.
+func (queue *Queue) Report() (interface{}, error) {
+	depths := make([]queueDepth, 0)
+	err := queue.client.DB.NewSelect().
+		Model((*jobs.Job)(nil)).
+		Column("queue", "status").
+		ColumnExpr("count(*) AS count").
+		Where("status IN (?, ?, ?)", jobs.JobStatusPending, jobs.JobStatusRunning, jobs.JobStatusDead).
+		Group("queue", "status").
+		Scan(context.Background(), &depths)
+	if err != nil {
+		return nil, err
+	}
+
+	report := make(map[string]map[jobs.JobStatus]int)
+	for _, depth := range depths {
+		if _, exists := report[depth.Queue]; !exists {
+			report[depth.Queue] = make(map[jobs.JobStatus]int)
+		}
+		report[depth.Queue][depth.Status] = depth.Count
+	}
+	return report, nil
+}
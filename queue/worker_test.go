@@ -0,0 +1,52 @@
+//go:build unit
+
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ginerator/base/model/jobs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextStatus(t *testing.T) {
+	queue := NewQueue(nil, Options{BaseBackoff: time.Second, MaxBackoff: 5 * time.Second})
+	jobErr := fmt.Errorf("Handler failed.")
+
+	tests := []struct {
+		name       string
+		attempts   int
+		err        error
+		status     jobs.JobStatus
+		maxBackoff time.Duration
+	}{
+		{"succeeded", 1, nil, jobs.JobStatusSucceeded, 0},
+		{"succeeded on the last attempt", 5, nil, jobs.JobStatusSucceeded, 0},
+		{"first failure", 1, jobErr, jobs.JobStatusPending, time.Second},
+		{"second failure", 2, jobErr, jobs.JobStatusPending, 2 * time.Second},
+		{"backoff capped", 4, jobErr, jobs.JobStatusPending, 5 * time.Second},
+		{"out of attempts", 5, jobErr, jobs.JobStatusDead, 0},
+		{"past the attempts", 6, jobErr, jobs.JobStatusDead, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, backoff := queue.nextStatus(jobs.Job{Attempts: test.attempts, MaxAttempts: 5}, test.err)
+			assert.Equal(t, test.status, status)
+			assert.LessOrEqual(t, backoff, test.maxBackoff)
+			assert.GreaterOrEqual(t, backoff, test.maxBackoff*8/10)
+		})
+	}
+}
+
+func TestRunHandlerRecoversPanics(t *testing.T) {
+	err := runHandler(context.Background(), func(ctx context.Context, payload json.RawMessage) error {
+		panic("boom")
+	}, jobs.Job{})
+
+	assert.EqualError(t, err, "Job panicked: boom")
+}
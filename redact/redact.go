@@ -0,0 +1,226 @@
+package redact
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Tag marks the fields never to be logged nor echoed: `redact:"true"`
+	Tag         = "redact"
+	Placeholder = "[REDACTED]"
+)
+
+var (
+	emailPattern       = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	bearerPattern      = regexp.MustCompile(`(?i)(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`)
+	jwtPattern         = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+	urlPasswordPattern = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.\-]*://[^:/@\s]*):[^@\s]*@`)
+	cardPattern        = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+)
+
+// String masks emails, bearer and JSON web tokens, URL passwords and card
+// numbers found in free text.
+func String(text string) string {
+	text = urlPasswordPattern.ReplaceAllString(text, "${1}:"+Placeholder+"@")
+	text = bearerPattern.ReplaceAllString(text, "${1} "+Placeholder)
+	text = jwtPattern.ReplaceAllString(text, Placeholder)
+	text = emailPattern.ReplaceAllString(text, Placeholder)
+	return cardPattern.ReplaceAllStringFunc(text, func(candidate string) string {
+		if isCardNumber(candidate) {
+			return Placeholder
+		}
+		return candidate
+	})
+}
+
+// DSN masks the password of a connection string.
+func DSN(dsn string) string {
+	return urlPasswordPattern.ReplaceAllString(dsn, "${1}:"+Placeholder+"@")
+}
+
+// isCardNumber tells whether digits, possibly spaced, pass the Luhn check,
+// so ids and timestamps of the same length aren't masked.
+func isCardNumber(candidate string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(candidate)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit, _ := strconv.Atoi(string(digits[i]))
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// IsRedactedField tells whether a struct field is tagged `redact:"true"`.
+func IsRedactedField(field reflect.StructField) bool {
+	redacted, _ := strconv.ParseBool(field.Tag.Get(Tag))
+	return redacted
+}
+
+// IsRedacted tells whether the field at a validator namespace, like
+// `CreateRoomRequest.Owner.Email` or `Items[0].Secret`, is redacted in s.
+func IsRedacted(s interface{}, namespace string) bool {
+	currentType := reflect.TypeOf(s)
+	segments := strings.Split(namespace, ".")
+	// The namespace starts with the name of the struct itself
+	for _, segment := range segments[1:] {
+		currentType = elementType(currentType)
+		if currentType == nil || currentType.Kind() != reflect.Struct {
+			return false
+		}
+		if index := strings.IndexByte(segment, '['); index >= 0 {
+			segment = segment[:index]
+		}
+		field, exists := currentType.FieldByName(segment)
+		if !exists {
+			return false
+		}
+		if IsRedactedField(field) {
+			return true
+		}
+		currentType = field.Type
+	}
+	return false
+}
+
+func elementType(t reflect.Type) reflect.Type {
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map) {
+		t = t.Elem()
+	}
+	return t
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Value returns a copy of v safe to log: structs become maps keyed like their
+// JSON, with redacted fields masked.
+func Value(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return value(reflect.ValueOf(v))
+}
+
+func value(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	// Types marshalling themselves, like time.Time or uuid.UUID, are kept
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nil
+		}
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return value(v.Elem())
+	case reflect.Struct:
+		return structValue(v)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		values := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			values[i] = value(v.Index(i))
+		}
+		return values
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		values := make(map[string]interface{}, v.Len())
+		iterator := v.MapRange()
+		for iterator.Next() {
+			values[fmtKey(iterator.Key())] = value(iterator.Value())
+		}
+		return values
+	default:
+		return v.Interface()
+	}
+}
+
+func structValue(v reflect.Value) map[string]interface{} {
+	values := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitEmpty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+		fieldValue := v.Field(i)
+		if field.Anonymous && name == "" && elementType(field.Type).Kind() == reflect.Struct {
+			if embedded, ok := value(fieldValue).(map[string]interface{}); ok {
+				for key, embeddedValue := range embedded {
+					values[key] = embeddedValue
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+		if IsRedactedField(field) {
+			values[name] = Placeholder
+			continue
+		}
+		values[name] = value(fieldValue)
+	}
+	return values
+}
+
+func jsonName(field reflect.StructField) (string, bool, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	return name, strings.Contains(options, "omitempty"), false
+}
+
+func fmtKey(key reflect.Value) string {
+	if key.Kind() == reflect.String {
+		return key.String()
+	}
+	if key.Type().Implements(textMarshalerType) {
+		if text, err := key.Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(key.Interface())
+}
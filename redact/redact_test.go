@@ -0,0 +1,87 @@
+//go:build unit
+
+package redact_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ginerator/base/redact"
+	"github.com/stretchr/testify/assert"
+)
+
+type card struct {
+	Number string `json:"number" redact:"true"`
+	Holder string `json:"holder"`
+}
+
+type paymentRequest struct {
+	Email string `json:"email" redact:"true"`
+	Card  card   `json:"card"`
+	Cards []card `json:"cards,omitempty"`
+	Notes string `json:"-"`
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"email", "user jane.doe+1@example.com signed up", "user [REDACTED] signed up"},
+		{"bearer", "Authorization: Bearer abc.def-ghi", "Authorization: Bearer [REDACTED]"},
+		{"jwt", "token=eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln", "token=[REDACTED]"},
+		{"dsn", "postgres://admin:s3cr3t@db:5432/rooms", "postgres://admin:[REDACTED]@db:5432/rooms"},
+		{"card", "paid with 4111 1111 1111 1111", "paid with [REDACTED]"},
+		{"not a card", "order 1234567890123", "order 1234567890123"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, redact.String(test.text))
+		})
+	}
+}
+
+func TestValue(t *testing.T) {
+	request := paymentRequest{Email: "jane@example.com", Card: card{Number: "4111111111111111", Holder: "Jane"}, Notes: "private"}
+
+	assert.Equal(t, map[string]interface{}{
+		"email": redact.Placeholder,
+		"card":  map[string]interface{}{"number": redact.Placeholder, "holder": "Jane"},
+	}, redact.Value(request))
+}
+
+func TestIsRedacted(t *testing.T) {
+	assert.True(t, redact.IsRedacted(paymentRequest{}, "paymentRequest.Email"))
+	assert.True(t, redact.IsRedacted(paymentRequest{}, "paymentRequest.Card.Number"))
+	assert.True(t, redact.IsRedacted(&paymentRequest{}, "paymentRequest.Cards[2].Number"))
+	assert.False(t, redact.IsRedacted(paymentRequest{}, "paymentRequest.Card.Holder"))
+	assert.False(t, redact.IsRedacted(paymentRequest{}, "paymentRequest.Unknown"))
+}
+
+func TestWriter(t *testing.T) {
+	output := &bytes.Buffer{}
+	writer := redact.NewWriter(output)
+
+	line := `{"level":"info","amount":4111111111111111,"total":-12.5,"order":1234567890123,"message":"paid by jane@example.com with 4111 1111 1111 1111"}` + "\n"
+	written, err := writer.Write([]byte(line))
+	assert.NoError(t, err)
+	assert.Equal(t, len(line), written)
+
+	assert.True(t, json.Valid(output.Bytes()))
+	var fields map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &fields))
+	assert.Equal(t, redact.Placeholder, fields["amount"])
+	assert.Equal(t, -12.5, fields["total"])
+	assert.Equal(t, float64(1234567890123), fields["order"])
+	assert.Equal(t, "paid by [REDACTED] with [REDACTED]", fields["message"])
+}
+
+func TestWriterText(t *testing.T) {
+	output := &bytes.Buffer{}
+	_, err := redact.NewWriter(output).Write([]byte("INF signed up jane@example.com"))
+	assert.NoError(t, err)
+	assert.Equal(t, "INF signed up [REDACTED]", output.String())
+}
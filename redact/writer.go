@@ -0,0 +1,98 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Writer masks the free text patterns String knows about in everything
+// written through it, meant to wrap the output of a logger.
+type Writer struct {
+	out io.Writer
+}
+
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out}
+}
+
+func (writer *Writer) Write(data []byte) (int, error) {
+	var masked []byte
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		masked = JSON(data)
+	} else {
+		masked = []byte(String(string(data)))
+	}
+	if _, err := writer.out.Write(masked); err != nil {
+		return 0, err
+	}
+	// Callers check the length they wrote, not the masked one
+	return len(data), nil
+}
+
+// JSON masks the strings of a JSON document like String does, and replaces
+// the numbers that look like card numbers with a quoted placeholder, so the
+// document stays valid.
+func JSON(data []byte) []byte {
+	masked := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		switch c := data[i]; {
+		case c == '"':
+			end := stringEnd(data, i)
+			masked = append(masked, String(string(data[i:end]))...)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(data) && strings.IndexByte("0123456789+-.eE", data[end]) >= 0 {
+				end++
+			}
+			if number := bytes.TrimPrefix(data[i:end], []byte("-")); isDigits(number) && isCardNumber(string(number)) {
+				masked = append(masked, strconv.Quote(Placeholder)...)
+			} else {
+				masked = append(masked, data[i:end]...)
+			}
+			i = end
+		default:
+			masked = append(masked, c)
+			i++
+		}
+	}
+	return masked
+}
+
+// stringEnd returns the index right after the string starting at start,
+// skipping escaped quotes.
+func stringEnd(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+func isDigits(data []byte) bool {
+	for _, c := range data {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(data) > 0
+}
+
+// Install redacts the global logger: its lines are written to out through a
+// Writer, and values logged with `Interface` have their redacted fields masked.
+func Install(out io.Writer) {
+	zerolog.InterfaceMarshalFunc = func(v interface{}) ([]byte, error) {
+		return json.Marshal(Value(v))
+	}
+	log.Logger = log.Logger.Output(NewWriter(out))
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/ginerator/base/logger"
+)
+
+const advisoryLockPollInterval = 500 * time.Millisecond
+
+// AdvisoryLockKey derives a stable Postgres advisory lock key from a name, so
+// every replica asking for the same name competes for the same lock.
+func AdvisoryLockKey(name string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	return int64(hash.Sum64())
+}
+
+// WithAdvisoryLock runs fn while holding the session level advisory lock key.
+// It waits up to timeout for the lock and fails if it can't be acquired.
+func (client *BunPostgresDatabaseClient) WithAdvisoryLock(ctx context.Context, key int64, timeout time.Duration, fn func() error) error {
+	conn, err := client.DB.Conn(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - WithAdvisoryLock - Could not get a connection")
+		return err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		acquired := false
+		err := conn.NewRaw("SELECT pg_try_advisory_lock(?)", key).Scan(ctx, &acquired)
+		if err != nil {
+			logger.For(ctx, logComponent).Error().Err(err).Int64("key", key).Msg("[POSTGRES CLIENT] - WithAdvisoryLock - Error acquiring lock")
+			return err
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Could not acquire advisory lock %d within %s.", key, timeout)
+		}
+
+		logger.For(ctx, logComponent).Info().Int64("key", key).Msg("[POSTGRES CLIENT] - WithAdvisoryLock - Waiting for lock")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(advisoryLockPollInterval):
+		}
+	}
+
+	defer func() {
+		// The lock is released even if ctx was cancelled while fn was running
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(?)", key)
+		if err != nil {
+			logger.For(ctx, logComponent).Error().Err(err).Int64("key", key).Msg("[POSTGRES CLIENT] - WithAdvisoryLock - Error releasing lock")
+		}
+	}()
+
+	return fn()
+}
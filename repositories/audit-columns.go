@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	user "github.com/ginerator/base/model/users"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+const (
+	ColumnCreatedAt = "created_at"
+	ColumnCreatedBy = "created_by"
+	ColumnUpdatedAt = "updated_at"
+	ColumnUpdatedBy = "updated_by"
+	ColumnDeletedAt = "deleted_at"
+	ColumnDeletedBy = "deleted_by"
+)
+
+func (r *PostgresRepository[M]) table() *schema.Table {
+	return r.client.DB.Table(reflect.TypeOf(new(M)).Elem())
+}
+
+func (r *PostgresRepository[M]) hasColumn(column string) bool {
+	_, exists := r.table().FieldMap[column]
+	return exists
+}
+
+// auditValues returns the audit columns of the model to fill for an action,
+// by column: timestamps are set by the database and actors come from the
+// authenticated user, if there's one.
+func (r *PostgresRepository[M]) auditValues(ctx *gin.Context, atColumn string, byColumn string) map[string]schema.QueryWithArgs {
+	values := make(map[string]schema.QueryWithArgs)
+	if r.hasColumn(atColumn) {
+		values[atColumn] = schema.SafeQuery("CURRENT_TIMESTAMP", nil)
+	}
+
+	contextUser := user.GetUser(ctx)
+	if contextUser != nil && contextUser.Actor() != nil && r.hasColumn(byColumn) {
+		values[byColumn] = schema.SafeQuery("?", []interface{}{*contextUser.Actor()})
+	}
+	return values
+}
+
+func (r *PostgresRepository[M]) setCreateAuditColumns(ctx *gin.Context, query *bun.InsertQuery) {
+	for _, columns := range [][2]string{{ColumnCreatedAt, ColumnCreatedBy}, {ColumnUpdatedAt, ColumnUpdatedBy}} {
+		for column, value := range r.auditValues(ctx, columns[0], columns[1]) {
+			query.Value(column, value.Query, value.Args...)
+		}
+	}
+}
+
+func (r *PostgresRepository[M]) setUpdateAuditColumns(ctx *gin.Context, query *bun.UpdateQuery) {
+	for column, value := range r.auditValues(ctx, ColumnUpdatedAt, ColumnUpdatedBy) {
+		query.Value(column, value.Query, value.Args...)
+	}
+}
+
+// setDeleteAuditColumns always sets deleted_at, the repository relies on it to
+// filter out soft deleted entities even when the model doesn't map it.
+func (r *PostgresRepository[M]) setDeleteAuditColumns(ctx *gin.Context, query *bun.UpdateQuery) {
+	query.Set("? = CURRENT_TIMESTAMP", bun.Ident(ColumnDeletedAt))
+	for _, columns := range [][2]string{{ColumnDeletedAt, ColumnDeletedBy}, {ColumnUpdatedAt, ColumnUpdatedBy}} {
+		for column, value := range r.auditValues(ctx, columns[0], columns[1]) {
+			if column == ColumnDeletedAt {
+				continue
+			}
+			query.Set("? = "+value.Query, append([]interface{}{bun.Ident(column)}, value.Args...)...)
+		}
+	}
+}
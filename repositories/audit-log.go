@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/audit"
+	modelquery "github.com/ginerator/base/model/query"
+	user "github.com/ginerator/base/model/users"
+	"github.com/ginerator/base/utils"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type auditLogHook struct{}
+
+// WithAuditLog records every write of the repository in the audit_log table,
+// inside the transaction of the write.
+func (r *PostgresRepository[M]) WithAuditLog() *PostgresRepository[M] {
+	return r.AddWriteHook(&auditLogHook{})
+}
+
+func (hook *auditLogHook) AfterWrite(ctx *gin.Context, db bun.IDB, change Change) error {
+	before, err := marshalAuditState(change.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshalAuditState(change.After)
+	if err != nil {
+		return err
+	}
+	changes, err := diffAuditStates(before, after)
+	if err != nil {
+		return err
+	}
+
+	entry := audit.AuditLogEntry{
+		EntityType: change.EntityType,
+		EntityId:   change.EntityId,
+		Action:     string(change.Action),
+		Before:     before,
+		After:      after,
+		Changes:    changes,
+	}
+
+	if contextUser := user.GetUser(ctx); contextUser != nil {
+		actorType := string(contextUser.Type)
+		entry.ActorId = contextUser.Actor()
+		entry.ActorType = &actorType
+	}
+	if requestId := utils.GetRequestId(ctx); requestId != "" {
+		entry.RequestId = &requestId
+	}
+
+	_, err = db.NewInsert().Model(&entry).Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().
+			Err(err).
+			Str("entityType", entry.EntityType).
+			Str("entityId", entry.EntityId).
+			Msg("[AUDIT LOG] - AfterWrite - Error inserting audit log entry")
+		return errors.NewUnkownDatabaseError(err)
+	}
+	return nil
+}
+
+func marshalAuditState(state interface{}) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+// diffAuditStates returns the top level attributes that changed between two
+// JSON objects, with their previous and new values.
+func diffAuditStates(before json.RawMessage, after json.RawMessage) (json.RawMessage, error) {
+	beforeAttributes := make(map[string]interface{})
+	afterAttributes := make(map[string]interface{})
+	if before != nil {
+		if err := json.Unmarshal(before, &beforeAttributes); err != nil {
+			return nil, err
+		}
+	}
+	if after != nil {
+		if err := json.Unmarshal(after, &afterAttributes); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := make(map[string]audit.FieldChange)
+	for attribute, beforeValue := range beforeAttributes {
+		afterValue, exists := afterAttributes[attribute]
+		if !exists || !reflect.DeepEqual(beforeValue, afterValue) {
+			changes[attribute] = audit.FieldChange{Before: beforeValue, After: afterValue}
+		}
+	}
+	for attribute, afterValue := range afterAttributes {
+		if _, exists := beforeAttributes[attribute]; !exists {
+			changes[attribute] = audit.FieldChange{After: afterValue}
+		}
+	}
+	return json.Marshal(changes)
+}
+
+// CreateAuditLogTable creates the audit_log table if it doesn't exist yet.
+func (client *BunPostgresDatabaseClient) CreateAuditLogTable(ctx context.Context) error {
+	_, err := client.DB.NewCreateTable().Model((*audit.AuditLogEntry)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - CreateAuditLogTable - Error creating table")
+		return err
+	}
+
+	_, err = client.DB.NewCreateIndex().
+		Model((*audit.AuditLogEntry)(nil)).
+		Index("audit_log_entity_idx").
+		IfNotExists().
+		Column("entity_type", "entity_id", "created_at").
+		Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - CreateAuditLogTable - Error creating index")
+	}
+	return err
+}
+
+// GetHistory returns the audit log entries of an entity, newest first. When
+// userId is set the entity has to belong to that user.
+func (r *PostgresRepository[M]) GetHistory(ctx *gin.Context, id uuid.UUID, userId *string) ([]audit.AuditLogEntry, modelquery.ResponseMeta, error) {
+	db := r.client.GetDB(ctx)
+	entries := make([]audit.AuditLogEntry, 0)
+	responseMeta := modelquery.ResponseMeta{}
+
+	if userId != nil {
+		exists, err := db.NewSelect().Model((*M)(nil)).Where("id = ?", id).Where("userId = ?", userId).Exists(ctx)
+		if err != nil {
+			logger.For(ctx, logComponent).Error().Err(err).Str("id", id.String()).Msg("[BASE REPOSITORY] - GetHistory - Checking ownership")
+			return entries, responseMeta, errors.NewUnkownDatabaseError(err)
+		}
+		if !exists {
+			return entries, responseMeta, errors.NewNotFoundError("NOT_FOUND", fmt.Errorf("Entity with id %s could not be found.", id))
+		}
+	}
+
+	dbQuery := db.NewSelect().
+		Model(&entries).
+		Where("entity_type = ?", r.table().Name).
+		Where("entity_id = ?", id.String())
+	offset, limit := utils.BuildControlQuery(ctx, dbQuery)
+
+	count, err := dbQuery.ScanAndCount(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().
+			Err(err).
+			Str("id", id.String()).
+			Str("entityType", r.table().Name).
+			Msg("[BASE REPOSITORY] - GetHistory - Unhandled error")
+		return entries, responseMeta, errors.NewInternalServerError("UNKNOWN_ERROR", err)
+	}
+
+	return entries, utils.BuildResponseMeta(offset, limit, count), nil
+}
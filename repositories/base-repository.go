@@ -6,43 +6,53 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
 	modelquery "github.com/ginerator/base/model/query"
 	"github.com/ginerator/base/utils"
 	"github.com/google/uuid"
-	"github.com/rs/zerolog/log"
+	"github.com/uptrace/bun"
 )
 
 type PostgresRepository[M interface{}] struct {
-	client *BunPostgresDatabaseClient
+	client     *BunPostgresDatabaseClient
+	writeHooks []WriteHook
+	versioned  bool
 }
 
 func NewPostgresRepository[M interface{}](dbClient *BunPostgresDatabaseClient) *PostgresRepository[M] {
-	log.Info().Msg("Room repository initialized.")
+	logger.Component(logComponent).Info().Msg("Room repository initialized.")
+	dbClient.RegisterModels(new(M))
 	return &PostgresRepository[M]{
 		client: dbClient,
 	}
 }
 
 func (r *PostgresRepository[M]) Create(ctx *gin.Context, createItemRequest interface{}) (M, error) {
-	db := r.client.getDB(ctx)
-
 	entity := new(M)
-	_, err := db.NewInsert().Model(createItemRequest).Returning("*").Exec(ctx, entity)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("model", fmt.Sprintf("%T", *entity)).
-			Msg("[BASE REPOSITORY] - Create - Inserting new entity")
-		return *entity, errors.NewUnkownDatabaseError(err)
-	}
-	return *entity, nil
+	err := r.write(ctx, func(db bun.IDB) (Change, error) {
+		query := db.NewInsert().Model(createItemRequest).Returning("*")
+		r.setCreateAuditColumns(ctx, query)
+		r.setCreateVersionColumns(query)
+
+		_, err := query.Exec(ctx, entity)
+		if err != nil {
+			logger.For(ctx, logComponent).Error().
+				Err(err).
+				Str("model", fmt.Sprintf("%T", *entity)).
+				Msg("[BASE REPOSITORY] - Create - Inserting new entity")
+			return Change{}, errors.NewUnkownDatabaseError(err)
+		}
+		return r.newChange(ChangeActionCreate, nil, entity), nil
+	})
+	return *entity, err
 }
 
-func (r *PostgresRepository[M]) GetOne(ctx *gin.Context, id uuid.UUID, userId *string) (M, error) {
-	db := r.client.getDB(ctx)
+func (r *PostgresRepository[M]) GetOne(ctx *gin.Context, id uuid.UUID, userId *string, opts ...ReadOption) (M, error) {
+	db := r.client.GetDB(ctx)
 
 	entity := new(M)
 	query := db.NewSelect().Model(entity).Where("id = ?", id).Where("deleted_at IS NULL")
+	r.filterVersions(query, r.buildReadOptions(ctx, opts))
 	if userId != nil {
 		query.Where("userId = ?", userId)
 	}
@@ -50,14 +60,14 @@ func (r *PostgresRepository[M]) GetOne(ctx *gin.Context, id uuid.UUID, userId *s
 	err := query.Scan(ctx)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Error().
+			logger.For(ctx, logComponent).Error().
 				Err(err).
 				Str("id", id.String()).
 				Str("model", fmt.Sprintf("%T", *entity)).
 				Msg("[BASE REPOSITORY] - GetOne - Not found")
 			return *entity, errors.NewNotFoundError("NOT_FOUND", fmt.Errorf("Entity with id %s could not be found.", id))
 		}
-		log.Error().
+		logger.For(ctx, logComponent).Error().
 			Err(err).
 			Str("id", id.String()).
 			Str("model", fmt.Sprintf("%T", *entity)).
@@ -68,13 +78,14 @@ func (r *PostgresRepository[M]) GetOne(ctx *gin.Context, id uuid.UUID, userId *s
 	return *entity, nil
 }
 
-func (r *PostgresRepository[M]) GetMany(ctx *gin.Context, query interface{}, userId *string) ([]M, modelquery.ResponseMeta, error) {
-	db := r.client.getDB(ctx)
+func (r *PostgresRepository[M]) GetMany(ctx *gin.Context, query interface{}, userId *string, opts ...ReadOption) ([]M, modelquery.ResponseMeta, error) {
+	db := r.client.GetDB(ctx)
 	entities := make([]M, 0)
 	entity := new(M) // Just to show it in a log
 	responseMeta := modelquery.ResponseMeta{}
 
 	dbQuery := db.NewSelect().Model(&entities)
+	r.filterVersions(dbQuery, r.buildReadOptions(ctx, opts))
 	if userId != nil {
 		dbQuery.Where("userId = ?", userId)
 	}
@@ -85,12 +96,12 @@ func (r *PostgresRepository[M]) GetMany(ctx *gin.Context, query interface{}, use
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Error().
+			logger.For(ctx, logComponent).Error().
 				Err(err).
 				Msg("[BASE REPOSITORY] - GetMany - Not found")
 			return entities, responseMeta, nil
 		}
-		log.Error().
+		logger.For(ctx, logComponent).Error().
 			Err(err).
 			Str("model", fmt.Sprintf("%T", *entity)).
 			Msg("[BASE REPOSITORY] - GetMany - Unhandled error")
@@ -102,24 +113,105 @@ func (r *PostgresRepository[M]) GetMany(ctx *gin.Context, query interface{}, use
 
 func (r *PostgresRepository[M]) UpdateOne(ctx *gin.Context, id uuid.UUID, request interface{}, userId *string) (M, error) {
 	entity := new(M)
+	err := r.write(ctx, func(db bun.IDB) (Change, error) {
+		before, err := r.lockForWrite(ctx, db, id, userId)
+		if err != nil {
+			return Change{}, err
+		}
+		if err := r.appendVersion(ctx, db, id, userId); err != nil {
+			return Change{}, err
+		}
+
+		query := db.NewUpdate().OmitZero().Model(request).Where("id = ?", id).Where("deleted_at IS NULL").Returning("*")
+		r.currentVersionOnly(query)
+		r.setUpdateAuditColumns(ctx, query)
+		if userId != nil {
+			logger.For(ctx, logComponent).Debug().
+				Str("id", id.String()).
+				Str("model", fmt.Sprintf("%T", *entity)).
+				Msg("[BASE REPOSITORY] - UpdateOne - Fetching with userId")
+			query.Where("userId = ?", userId)
+		}
+
+		_, err = query.Exec(ctx, entity)
+		if err != nil {
+			logger.For(ctx, logComponent).Error().
+				Err(err).
+				Str("id", id.String()).
+				Str("model", fmt.Sprintf("%T", *entity)).
+				Msg("[BASE REPOSITORY] - UpdateOne - Error updating")
+			return Change{}, err
+		}
+		return r.newChange(ChangeActionUpdate, before, entity), nil
+	})
+	return *entity, err
+}
 
-	query := r.client.getDB(ctx).NewUpdate().OmitZero().Model(request).Where("id = ?", id).Where("deleted_at IS NULL").Returning("*")
+func (r *PostgresRepository[M]) DeleteOne(ctx *gin.Context, id uuid.UUID, userId *string) (M, error) {
+	entity := new(M)
+	err := r.write(ctx, func(db bun.IDB) (Change, error) {
+		before, err := r.lockForWrite(ctx, db, id, userId)
+		if err != nil {
+			return Change{}, err
+		}
+		if err := r.appendVersion(ctx, db, id, userId); err != nil {
+			return Change{}, err
+		}
+
+		query := db.NewUpdate().Model(entity).Where("id = ?", id).Where("deleted_at IS NULL").Returning("*")
+		r.currentVersionOnly(query)
+		r.setDeleteAuditColumns(ctx, query)
+		if userId != nil {
+			query.Where("userId = ?", userId)
+		}
+
+		_, err = query.Exec(ctx, entity)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				logger.For(ctx, logComponent).Error().
+					Err(err).
+					Str("id", id.String()).
+					Str("model", fmt.Sprintf("%T", *entity)).
+					Msg("[BASE REPOSITORY] - DeleteOne - Not found")
+				return Change{}, errors.NewNotFoundError("NOT_FOUND", fmt.Errorf("Entity with id %s could not be found.", id))
+			}
+			logger.For(ctx, logComponent).Error().
+				Err(err).
+				Str("id", id.String()).
+				Str("model", fmt.Sprintf("%T", *entity)).
+				Msg("[BASE REPOSITORY] - DeleteOne - Error deleting")
+			return Change{}, errors.NewUnkownDatabaseError(err)
+		}
+		return r.newChange(ChangeActionDelete, before, entity), nil
+	})
+	return *entity, err
+}
+
+// lockForWrite reads the entity about to be changed and locks its row, so
+// write hooks get its previous state. Without hooks it's a no-op.
+func (r *PostgresRepository[M]) lockForWrite(ctx *gin.Context, db bun.IDB, id uuid.UUID, userId *string) (*M, error) {
+	if !r.hasWriteHooks() {
+		return nil, nil
+	}
+
+	before := new(M)
+	query := db.NewSelect().Model(before).Where("id = ?", id).Where("deleted_at IS NULL").For("UPDATE")
+	r.filterVersions(query, readOptions{})
 	if userId != nil {
-		log.Debug().
-			Str("id", id.String()).
-			Str("model", fmt.Sprintf("%T", *entity)).
-			Msg("[BASE REPOSITORY] - UpdateOne - Fetching with userId")
 		query.Where("userId = ?", userId)
 	}
 
-	_, err := query.Exec(ctx, entity)
+	err := query.Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		log.Error().
+		logger.For(ctx, logComponent).Error().
 			Err(err).
 			Str("id", id.String()).
-			Str("model", fmt.Sprintf("%T", *entity)).
-			Msg("[BASE REPOSITORY] - UpdateOne - Error updating")
-		return *entity, err
+			Str("model", fmt.Sprintf("%T", *before)).
+			Msg("[BASE REPOSITORY] - lockForWrite - Error reading entity")
+		return nil, errors.NewUnkownDatabaseError(err)
 	}
-	return *entity, nil
+	return before, nil
 }
@@ -0,0 +1,418 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/events"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+const (
+	changeFeedChannelPrefix = "change_feed_"
+	// changeFeedBufferSize is how many events a slow subscriber can fall
+	// behind before it's disconnected and has to resume with Last-Event-ID.
+	changeFeedBufferSize  = 64
+	changeFeedReplayLimit = 1000
+	// changeFeedSeenIds is how many sent ids a subscriber remembers to drop
+	// duplicates between the replay and the live stream
+	changeFeedSeenIds = 4 * changeFeedReplayLimit
+)
+
+// ChangeFeedChannel is the NOTIFY channel the writes of an entity type are sent on.
+func ChangeFeedChannel(entityType string) string {
+	return changeFeedChannelPrefix + entityType
+}
+
+type changeFeedHook struct {
+	ownerId func(entity interface{}) *string
+}
+
+// WithChangeFeed stores every write of the repository in the change_events
+// table and notifies the listeners of the entity type once it commits.
+func (r *PostgresRepository[M]) WithChangeFeed() *PostgresRepository[M] {
+	return r.AddWriteHook(&changeFeedHook{ownerId: r.ownerId})
+}
+
+func (hook *changeFeedHook) AfterWrite(ctx *gin.Context, db bun.IDB, change Change) error {
+	entity := change.After
+	if entity == nil {
+		entity = change.Before
+	}
+	payload, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+
+	event := events.ChangeEvent{
+		EntityType: change.EntityType,
+		EntityId:   change.EntityId,
+		Action:     string(change.Action),
+		UserId:     hook.ownerId(entity),
+		Payload:    payload,
+	}
+	_, err = db.NewInsert().Model(&event).Returning("id, created_at").Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("entityType", event.EntityType).Msg("[CHANGE FEED] - AfterWrite - Error inserting change event")
+		return errors.NewUnkownDatabaseError(err)
+	}
+
+	// NOTIFY is transactional, listeners only hear about committed writes
+	_, err = db.NewRaw("SELECT pg_notify(?, ?)", ChangeFeedChannel(event.EntityType), strconv.FormatInt(event.Id, 10)).Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("entityType", event.EntityType).Msg("[CHANGE FEED] - AfterWrite - Error notifying change event")
+		return errors.NewUnkownDatabaseError(err)
+	}
+	return nil
+}
+
+// ownerId returns the `userId` attribute of an entity, the one GetUserId
+// ownership filters apply to, or nil if the model has none.
+func (r *PostgresRepository[M]) ownerId(entity interface{}) *string {
+	for _, field := range r.table().Fields {
+		if !strings.EqualFold(field.Name, "userid") {
+			continue
+		}
+		value := reflect.ValueOf(entity)
+		if value.Kind() == reflect.Ptr {
+			value = value.Elem()
+		}
+		fieldValue := field.Value(value)
+		if fieldValue.Kind() == reflect.Ptr {
+			if fieldValue.IsNil() {
+				return nil
+			}
+			fieldValue = fieldValue.Elem()
+		}
+		owner := fieldValue.String()
+		return &owner
+	}
+	return nil
+}
+
+// Subscribe streams the change events of the repository's entity type. With a
+// lastEventId the events after it are replayed first, page by page, until
+// the replay catches up with the live stream. When userId is set only the
+// events of entities owned by that user are sent. The returned function has
+// to be called to unsubscribe; the channel is closed when the subscriber
+// falls too far behind or the client is closed.
+func (r *PostgresRepository[M]) Subscribe(ctx *gin.Context, lastEventId int64, userId *string) (<-chan events.ChangeEvent, func(), error) {
+	feed := r.client.ChangeFeed()
+	subscription, err := feed.subscribe(ctx, r.table().Name, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replay := make([]events.ChangeEvent, 0)
+	if lastEventId > 0 {
+		if replay, err = r.replayPage(ctx, lastEventId, userId); err != nil {
+			feed.unsubscribe(subscription)
+			logger.For(ctx, logComponent).Error().Err(err).Int64("lastEventId", lastEventId).Msg("[CHANGE FEED] - Subscribe - Error replaying events")
+			return nil, nil, errors.NewUnkownDatabaseError(err)
+		}
+	}
+
+	stream := make(chan events.ChangeEvent, changeFeedBufferSize)
+	stop := make(chan struct{})
+	requestCtx := ctx.Request.Context()
+	go func() {
+		defer close(stream)
+
+		// Events committed during the replay come through both ways, and ids
+		// aren't committed in order, so sent ids are remembered rather than
+		// only the highest one
+		seen := newSeenEventIds(changeFeedSeenIds)
+		pending := make([]events.ChangeEvent, 0)
+		send := func(event events.ChangeEvent) bool {
+			if seen.contains(event.Id) {
+				return true
+			}
+			for {
+				select {
+				case stream <- event:
+					seen.add(event.Id)
+					return true
+				case live, open := <-subscription.events:
+					// Live events are kept aside during the replay so the
+					// subscription isn't dropped as a slow one
+					if !open || len(pending) >= changeFeedReplayLimit {
+						return false
+					}
+					pending = append(pending, live)
+				case <-stop:
+					return false
+				}
+			}
+		}
+
+		for len(replay) > 0 {
+			for _, event := range replay {
+				if !send(event) {
+					return
+				}
+			}
+			if len(replay) < changeFeedReplayLimit {
+				break
+			}
+			var err error
+			if replay, err = r.replayPage(requestCtx, replay[len(replay)-1].Id, userId); err != nil {
+				logger.For(requestCtx, logComponent).Error().Err(err).Msg("[CHANGE FEED] - Subscribe - Error replaying events")
+				return
+			}
+		}
+		for len(pending) > 0 {
+			event := pending[0]
+			pending = pending[1:]
+			if !send(event) {
+				return
+			}
+		}
+
+		for {
+			select {
+			case event, open := <-subscription.events:
+				if !open || !send(event) {
+					return
+				}
+				for len(pending) > 0 {
+					event := pending[0]
+					pending = pending[1:]
+					if !send(event) {
+						return
+					}
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return stream, func() {
+		once.Do(func() {
+			close(stop)
+			feed.unsubscribe(subscription)
+		})
+	}, nil
+}
+
+func (r *PostgresRepository[M]) replayPage(ctx context.Context, afterId int64, userId *string) ([]events.ChangeEvent, error) {
+	page := make([]events.ChangeEvent, 0)
+	query := r.client.DB.NewSelect().
+		Model(&page).
+		Where("entity_type = ?", r.table().Name).
+		Where("id > ?", afterId).
+		OrderExpr("id ASC").
+		Limit(changeFeedReplayLimit)
+	if userId != nil {
+		query.Where("user_id = ?", userId)
+	}
+	err := query.Scan(ctx)
+	return page, err
+}
+
+// seenEventIds remembers the last ids sent to a subscriber, forgetting the
+// oldest ones past its capacity.
+type seenEventIds struct {
+	ids   map[int64]struct{}
+	order []int64
+	next  int
+}
+
+func newSeenEventIds(capacity int) *seenEventIds {
+	return &seenEventIds{ids: make(map[int64]struct{}, capacity), order: make([]int64, 0, capacity)}
+}
+
+func (seen *seenEventIds) contains(id int64) bool {
+	_, exists := seen.ids[id]
+	return exists
+}
+
+func (seen *seenEventIds) add(id int64) {
+	if len(seen.order) < cap(seen.order) {
+		seen.order = append(seen.order, id)
+	} else {
+		delete(seen.ids, seen.order[seen.next])
+		seen.order[seen.next] = id
+		seen.next = (seen.next + 1) % len(seen.order)
+	}
+	seen.ids[id] = struct{}{}
+}
+
+type changeSubscription struct {
+	entityType string
+	userId     *string
+	events     chan events.ChangeEvent
+}
+
+// ChangeFeed LISTENs on the change feed channels of the subscribed entity
+// types and fans the events out to the subscribers of this process.
+type ChangeFeed struct {
+	client   *BunPostgresDatabaseClient
+	listener *pgdriver.Listener
+
+	subscriptions map[string]map[*changeSubscription]struct{}
+	mutex         sync.Mutex
+
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// ChangeFeed returns the change feed of the client, starting it on first use.
+func (client *BunPostgresDatabaseClient) ChangeFeed() *ChangeFeed {
+	client.changeFeedOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		feed := &ChangeFeed{
+			client:        client,
+			listener:      pgdriver.NewListener(client.DB),
+			subscriptions: make(map[string]map[*changeSubscription]struct{}),
+			cancel:        cancel,
+		}
+		feed.done.Add(1)
+		go feed.receive(ctx)
+		client.changeFeed = feed
+		logger.For(ctx, logComponent).Info().Msg("Change feed listener started.")
+	})
+	return client.changeFeed
+}
+
+func (feed *ChangeFeed) subscribe(ctx context.Context, entityType string, userId *string) (*changeSubscription, error) {
+	subscription := &changeSubscription{
+		entityType: entityType,
+		userId:     userId,
+		events:     make(chan events.ChangeEvent, changeFeedBufferSize),
+	}
+
+	feed.mutex.Lock()
+	defer feed.mutex.Unlock()
+
+	if _, listening := feed.subscriptions[entityType]; !listening {
+		if err := feed.listener.Listen(ctx, ChangeFeedChannel(entityType)); err != nil {
+			logger.For(ctx, logComponent).Error().Err(err).Str("entityType", entityType).Msg("[CHANGE FEED] - subscribe - Error listening")
+			return nil, errors.NewUnkownDatabaseError(err)
+		}
+		feed.subscriptions[entityType] = make(map[*changeSubscription]struct{})
+	}
+	feed.subscriptions[entityType][subscription] = struct{}{}
+	return subscription, nil
+}
+
+func (feed *ChangeFeed) unsubscribe(subscription *changeSubscription) {
+	feed.mutex.Lock()
+	defer feed.mutex.Unlock()
+
+	if _, exists := feed.subscriptions[subscription.entityType][subscription]; exists {
+		delete(feed.subscriptions[subscription.entityType], subscription)
+		close(subscription.events)
+	}
+}
+
+func (feed *ChangeFeed) receive(ctx context.Context) {
+	defer feed.done.Done()
+
+	notifications := feed.listener.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification, open := <-notifications:
+			if !open {
+				return
+			}
+			if !strings.HasPrefix(notification.Channel, changeFeedChannelPrefix) {
+				continue
+			}
+			feed.dispatch(ctx, notification.Payload)
+		}
+	}
+}
+
+func (feed *ChangeFeed) dispatch(ctx context.Context, payload string) {
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("payload", payload).Msg("[CHANGE FEED] - dispatch - Invalid notification")
+		return
+	}
+
+	// The notification only carries the id, payloads can exceed the NOTIFY limit
+	event := events.ChangeEvent{Id: id}
+	if err := feed.client.DB.NewSelect().Model(&event).WherePK().Scan(ctx); err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Int64("id", id).Msg("[CHANGE FEED] - dispatch - Error loading change event")
+		return
+	}
+
+	feed.mutex.Lock()
+	defer feed.mutex.Unlock()
+	for subscription := range feed.subscriptions[event.EntityType] {
+		if subscription.userId != nil && (event.UserId == nil || *event.UserId != *subscription.userId) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			logger.For(ctx, logComponent).Warn().Str("entityType", event.EntityType).Msg("[CHANGE FEED] - dispatch - Dropping slow subscriber")
+			delete(feed.subscriptions[event.EntityType], subscription)
+			close(subscription.events)
+		}
+	}
+}
+
+// Close stops listening and closes the channels of every subscriber.
+func (feed *ChangeFeed) Close() {
+	feed.cancel()
+	feed.listener.Close()
+	feed.done.Wait()
+
+	feed.mutex.Lock()
+	defer feed.mutex.Unlock()
+	for entityType, subscriptions := range feed.subscriptions {
+		for subscription := range subscriptions {
+			close(subscription.events)
+		}
+		delete(feed.subscriptions, entityType)
+	}
+	logger.Component(logComponent).Info().Msg("Change feed listener stopped.")
+}
+
+// PruneChangeEvents deletes the change events older than retention. Clients
+// resuming from a pruned event only get the ones still stored.
+func (client *BunPostgresDatabaseClient) PruneChangeEvents(ctx context.Context, retention time.Duration) (int64, error) {
+	result, err := client.DB.NewDelete().
+		Model((*events.ChangeEvent)(nil)).
+		Where("created_at < ?", time.Now().Add(-retention)).
+		Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[CHANGE FEED] - PruneChangeEvents - Error deleting events")
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CreateChangeEventsTable creates the change_events table if it doesn't exist yet.
+func (client *BunPostgresDatabaseClient) CreateChangeEventsTable(ctx context.Context) error {
+	_, err := client.DB.NewCreateTable().Model((*events.ChangeEvent)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - CreateChangeEventsTable - Error creating table")
+		return err
+	}
+
+	_, err = client.DB.NewCreateIndex().
+		Model((*events.ChangeEvent)(nil)).
+		Index("change_events_entity_type_idx").
+		IfNotExists().
+		Column("entity_type", "id").
+		Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - CreateChangeEventsTable - Error creating index")
+	}
+	return err
+}
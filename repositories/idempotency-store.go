@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/idempotency"
+)
+
+// PostgresIdempotencyStore keeps idempotency records in the idempotency_keys
+// table, shared by every replica.
+type PostgresIdempotencyStore struct {
+	client *BunPostgresDatabaseClient
+}
+
+func NewPostgresIdempotencyStore(client *BunPostgresDatabaseClient) *PostgresIdempotencyStore {
+	return &PostgresIdempotencyStore{client: client}
+}
+
+func (store *PostgresIdempotencyStore) Begin(ctx context.Context, record idempotency.Record) (*idempotency.Record, error) {
+	// Expired and abandoned records are taken over, live ones are left alone
+	result, err := store.client.DB.NewInsert().
+		Model(&record).
+		On("CONFLICT (scope, key) DO UPDATE").
+		Set("request_hash = EXCLUDED.request_hash").
+		Set("status = 0").
+		Set("content_type = NULL").
+		Set("body = NULL").
+		Set("locked_until = EXCLUDED.locked_until").
+		Set("expires_at = EXCLUDED.expires_at").
+		Set("created_at = EXCLUDED.created_at").
+		Where("?TableAlias.expires_at < CURRENT_TIMESTAMP OR (?TableAlias.status = 0 AND ?TableAlias.locked_until < CURRENT_TIMESTAMP)").
+		Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("key", record.Key).Msg("[IDEMPOTENCY] - Begin - Error inserting key")
+		return nil, err
+	}
+	if inserted, _ := result.RowsAffected(); inserted > 0 {
+		return nil, nil
+	}
+
+	existing := idempotency.Record{Scope: record.Scope, Key: record.Key}
+	if err := store.client.DB.NewSelect().Model(&existing).WherePK().Scan(ctx); err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("key", record.Key).Msg("[IDEMPOTENCY] - Begin - Error reading key")
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Complete stores the response, unless the key was taken over by another
+// request since.
+func (store *PostgresIdempotencyStore) Complete(ctx context.Context, record idempotency.Record) error {
+	_, err := store.client.DB.NewUpdate().
+		Model(&record).
+		Column("status", "content_type", "body").
+		WherePK().
+		Where("status = 0").
+		Where("locked_until = ?", record.LockedUntil).
+		Exec(ctx)
+	return err
+}
+
+// Release deletes the in flight record only while this request still holds it.
+func (store *PostgresIdempotencyStore) Release(ctx context.Context, record idempotency.Record) error {
+	_, err := store.client.DB.NewDelete().
+		Model((*idempotency.Record)(nil)).
+		Where("scope = ?", record.Scope).
+		Where("key = ?", record.Key).
+		Where("status = 0").
+		Where("locked_until = ?", record.LockedUntil).
+		Exec(ctx)
+	return err
+}
+
+// Prune deletes the expired records.
+func (store *PostgresIdempotencyStore) Prune(ctx context.Context) (int64, error) {
+	result, err := store.client.DB.NewDelete().
+		Model((*idempotency.Record)(nil)).
+		Where("expires_at < ?", time.Now()).
+		Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[IDEMPOTENCY] - Prune - Error deleting expired keys")
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CreateIdempotencyTable creates the idempotency_keys table if it doesn't exist yet.
+func (client *BunPostgresDatabaseClient) CreateIdempotencyTable(ctx context.Context) error {
+	_, err := client.DB.NewCreateTable().Model((*idempotency.Record)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - CreateIdempotencyTable - Error creating table")
+	}
+	return err
+}
@@ -0,0 +1,215 @@
+package postgres
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type MigrationLintRule string
+
+const (
+	MigrationLintRuleNotNullWithoutDefault MigrationLintRule = "not-null-without-default"
+	MigrationLintRuleIndexNotConcurrent    MigrationLintRule = "index-not-concurrent"
+	MigrationLintRuleColumnTypeChange      MigrationLintRule = "column-type-change"
+	MigrationLintRuleDropColumn            MigrationLintRule = "drop-column"
+)
+
+type MigrationLintIssue struct {
+	File      string            `json:"file"`
+	Line      int               `json:"line"`
+	Rule      MigrationLintRule `json:"rule"`
+	Statement string            `json:"statement"`
+	Message   string            `json:"message"`
+}
+
+type migrationStatement struct {
+	line int
+	sql  string
+}
+
+var (
+	lintIgnoreAnnotation      = regexp.MustCompile(`(?i)--\s*lint:ignore\s+([a-z\-, ]+)`)
+	deprecatedSinceAnnotation = regexp.MustCompile(`(?i)--\s*deprecated-since:\s*(\d{4}-\d{2}-\d{2})`)
+
+	createTableStatement = regexp.MustCompile(`^CREATE (?:UNLOGGED |TEMP |TEMPORARY )?TABLE (?:IF NOT EXISTS )?([^\s(]+)`)
+	createIndexStatement = regexp.MustCompile(`^CREATE (?:UNIQUE )?INDEX\b`)
+	indexTable           = regexp.MustCompile(` ON (?:ONLY )?([^\s(]+)`)
+	alterTableStatement  = regexp.MustCompile(`^ALTER TABLE (?:IF EXISTS )?(?:ONLY )?(\S+) (.*)$`)
+
+	addColumnAction     = regexp.MustCompile(`^ADD (?:COLUMN )?`)
+	addConstraintAction = regexp.MustCompile(`^ADD (?:CONSTRAINT|PRIMARY KEY|UNIQUE|FOREIGN KEY|CHECK|EXCLUDE)\b`)
+	changeTypeAction    = regexp.MustCompile(`^ALTER (?:COLUMN )?\S+ (?:SET DATA )?TYPE `)
+	dropColumnAction    = regexp.MustCompile(`^DROP (?:COLUMN )?`)
+	dropOtherAction     = regexp.MustCompile(`^DROP (?:CONSTRAINT|DEFAULT|NOT NULL|IDENTITY|EXPRESSION)\b`)
+)
+
+// LintMigration flags statements of a migration file that usually lock or
+// rewrite a table in production. A rule can be silenced for a file with a
+// `-- lint:ignore <rule>` comment, and columns can only be dropped once the
+// file carries a `-- deprecated-since: YYYY-MM-DD` comment older than the
+// deprecation window.
+func LintMigration(file string, content string, deprecationWindow time.Duration, now time.Time) []MigrationLintIssue {
+	ignored := map[MigrationLintRule]bool{}
+	for _, match := range lintIgnoreAnnotation.FindAllStringSubmatch(content, -1) {
+		for _, rule := range strings.Split(match[1], ",") {
+			ignored[MigrationLintRule(strings.ToLower(strings.TrimSpace(rule)))] = true
+		}
+	}
+
+	var deprecatedSince *time.Time
+	if match := deprecatedSinceAnnotation.FindStringSubmatch(content); match != nil {
+		if date, err := time.Parse("2006-01-02", match[1]); err == nil {
+			deprecatedSince = &date
+		}
+	}
+
+	statements := splitMigrationStatements(content)
+
+	// Tables created in the same file are empty, so locking them is harmless
+	createdTables := map[string]bool{}
+	for _, statement := range statements {
+		if match := createTableStatement.FindStringSubmatch(statement.sql); match != nil {
+			createdTables[normalizeTableName(match[1])] = true
+		}
+	}
+
+	issues := make([]MigrationLintIssue, 0)
+	report := func(statement migrationStatement, rule MigrationLintRule, message string) {
+		if ignored[rule] {
+			return
+		}
+		issues = append(issues, MigrationLintIssue{
+			File:      file,
+			Line:      statement.line,
+			Rule:      rule,
+			Statement: statement.sql,
+			Message:   message,
+		})
+	}
+
+	for _, statement := range statements {
+		if createIndexStatement.MatchString(statement.sql) {
+			match := indexTable.FindStringSubmatch(statement.sql)
+			if !strings.Contains(statement.sql, " CONCURRENTLY ") && (match == nil || !createdTables[normalizeTableName(match[1])]) {
+				report(statement, MigrationLintRuleIndexNotConcurrent, "CREATE INDEX without CONCURRENTLY blocks writes on the table while the index is built.")
+			}
+			continue
+		}
+
+		match := alterTableStatement.FindStringSubmatch(statement.sql)
+		if match == nil {
+			continue
+		}
+		isNewTable := createdTables[normalizeTableName(match[1])]
+
+		for _, action := range splitAlterTableActions(match[2]) {
+			switch {
+			case addColumnAction.MatchString(action) && !addConstraintAction.MatchString(action):
+				if !isNewTable && strings.Contains(action, "NOT NULL") && !strings.Contains(action, "DEFAULT") && !strings.Contains(action, "GENERATED") {
+					report(statement, MigrationLintRuleNotNullWithoutDefault, "ADD COLUMN ... NOT NULL without a DEFAULT fails on non empty tables and locks the table.")
+				}
+			case changeTypeAction.MatchString(action):
+				if !isNewTable {
+					report(statement, MigrationLintRuleColumnTypeChange, "Changing a column type can rewrite the whole table under an exclusive lock.")
+				}
+			case dropColumnAction.MatchString(action) && !dropOtherAction.MatchString(action):
+				if deprecatedSince == nil {
+					report(statement, MigrationLintRuleDropColumn, "DROP COLUMN requires a '-- deprecated-since: YYYY-MM-DD' annotation so running code stops using the column first.")
+				} else if now.Sub(*deprecatedSince) < deprecationWindow {
+					report(statement, MigrationLintRuleDropColumn, fmt.Sprintf("DROP COLUMN is only allowed %s after the column was deprecated on %s.", deprecationWindow, deprecatedSince.Format("2006-01-02")))
+				}
+			}
+		}
+	}
+
+	return issues
+}
+
+// splitMigrationStatements strips comments and splits the file on semicolons,
+// keeping the line where each statement starts. Statements are upper cased
+// and their whitespace collapsed.
+func splitMigrationStatements(content string) []migrationStatement {
+	statements := make([]migrationStatement, 0)
+
+	var current strings.Builder
+	line, startLine := 1, 0
+	inQuote, inLineComment, inBlockComment := false, false, false
+
+	flush := func() {
+		sql := strings.Join(strings.Fields(strings.ToUpper(current.String())), " ")
+		if sql != "" {
+			statements = append(statements, migrationStatement{line: startLine, sql: sql})
+		}
+		current.Reset()
+		startLine = 0
+	}
+
+	for i := 0; i < len(content); i++ {
+		char := content[i]
+		next := byte(0)
+		if i+1 < len(content) {
+			next = content[i+1]
+		}
+
+		switch {
+		case inLineComment:
+			if char == '\n' {
+				inLineComment = false
+				current.WriteByte(' ')
+			}
+		case inBlockComment:
+			if char == '*' && next == '/' {
+				inBlockComment = false
+				i++
+			}
+		case !inQuote && char == '-' && next == '-':
+			inLineComment = true
+		case !inQuote && char == '/' && next == '*':
+			inBlockComment = true
+		case !inQuote && char == ';':
+			flush()
+		default:
+			if char == '\'' {
+				inQuote = !inQuote
+			}
+			if startLine == 0 && char != ' ' && char != '\t' && char != '\n' && char != '\r' {
+				startLine = line
+			}
+			current.WriteByte(char)
+		}
+
+		if char == '\n' {
+			line++
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// splitAlterTableActions splits the action list of an ALTER TABLE on the
+// commas that aren't nested inside parentheses.
+func splitAlterTableActions(actions string) []string {
+	result := make([]string, 0)
+	depth, start := 0, 0
+	for i, char := range actions {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				result = append(result, strings.TrimSpace(actions[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(result, strings.TrimSpace(actions[start:]))
+}
+
+func normalizeTableName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, `"`, ""))
+}
@@ -0,0 +1,97 @@
+//go:build unit
+
+package postgres_test
+
+import (
+	"testing"
+	"time"
+
+	postgres "github.com/ginerator/base/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintMigration(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	window := 7 * 24 * time.Hour
+
+	type testData struct {
+		name     string
+		content  string
+		expected []postgres.MigrationLintRule
+	}
+
+	testCases := []testData{
+		{
+			name:     "not null column without default",
+			content:  "ALTER TABLE items ADD COLUMN price integer NOT NULL;",
+			expected: []postgres.MigrationLintRule{postgres.MigrationLintRuleNotNullWithoutDefault},
+		},
+		{
+			name:     "not null column with default",
+			content:  "ALTER TABLE items ADD COLUMN price integer NOT NULL DEFAULT 0;",
+			expected: []postgres.MigrationLintRule{},
+		},
+		{
+			name:     "index without concurrently",
+			content:  "CREATE INDEX items_name_idx ON items (name);",
+			expected: []postgres.MigrationLintRule{postgres.MigrationLintRuleIndexNotConcurrent},
+		},
+		{
+			name:     "index concurrently",
+			content:  "CREATE INDEX CONCURRENTLY items_name_idx ON items (name);",
+			expected: []postgres.MigrationLintRule{},
+		},
+		{
+			name: "index on a table created in the same file",
+			content: `CREATE TABLE rooms (id uuid PRIMARY KEY, name text NOT NULL);
+				CREATE INDEX rooms_name_idx ON rooms (name);`,
+			expected: []postgres.MigrationLintRule{},
+		},
+		{
+			name:     "column type change",
+			content:  "ALTER TABLE items ALTER COLUMN price TYPE bigint;",
+			expected: []postgres.MigrationLintRule{postgres.MigrationLintRuleColumnTypeChange},
+		},
+		{
+			name:     "drop column without deprecation",
+			content:  "ALTER TABLE items DROP COLUMN price, DROP CONSTRAINT items_price_check;",
+			expected: []postgres.MigrationLintRule{postgres.MigrationLintRuleDropColumn},
+		},
+		{
+			name:     "drop column inside the deprecation window",
+			content:  "-- deprecated-since: 2026-09-28\nALTER TABLE items DROP COLUMN price;",
+			expected: []postgres.MigrationLintRule{postgres.MigrationLintRuleDropColumn},
+		},
+		{
+			name:     "drop column after the deprecation window",
+			content:  "-- deprecated-since: 2026-09-01\nALTER TABLE items DROP COLUMN price;",
+			expected: []postgres.MigrationLintRule{},
+		},
+		{
+			name:     "ignored rule",
+			content:  "-- lint:ignore index-not-concurrent\nCREATE UNIQUE INDEX items_name_idx ON items (name);",
+			expected: []postgres.MigrationLintRule{},
+		},
+		{
+			name:     "commented out statement",
+			content:  "-- CREATE INDEX items_name_idx ON items (name);\nSELECT 1;",
+			expected: []postgres.MigrationLintRule{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		issues := postgres.LintMigration("1_test.up.sql", testCase.content, window, now)
+		actual := make([]postgres.MigrationLintRule, 0)
+		for _, issue := range issues {
+			actual = append(actual, issue.Rule)
+		}
+		assert.Equal(t, testCase.expected, actual, testCase.name)
+	}
+}
+
+func TestLintMigrationLine(t *testing.T) {
+	content := "CREATE TABLE rooms (id uuid);\n\n-- Slow on big tables\nALTER TABLE items\n  ALTER COLUMN price TYPE bigint;"
+	issues := postgres.LintMigration("1_test.up.sql", content, time.Hour, time.Now())
+	assert.Len(t, issues, 1)
+	assert.Equal(t, 4, issues[0].Line)
+}
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ginerator/base/logger"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+const (
+	MigrationLintOff  = "off"
+	MigrationLintWarn = "warn"
+	MigrationLintFail = "fail"
+
+	defaultMigrationLockTimeout       = 60 * time.Second
+	defaultMigrationDeprecationWindow = 7 * 24 * time.Hour
+	// Reading the migration version opens a connection of its own, so
+	// CachedMigrationVersion refreshes it at most this often.
+	migrationVersionTTL = time.Minute
+)
+
+type cachedMigrationVersion struct {
+	mutex     sync.Mutex
+	version   uint
+	dirty     bool
+	err       error
+	checkedAt time.Time
+}
+
+type PendingMigration struct {
+	Version    uint
+	Identifier string
+	File       string
+}
+
+func (client *BunPostgresDatabaseClient) newMigrate() (*migrate.Migrate, error) {
+	m, err := migrate.New(fmt.Sprintf("file://%s", client.MigrationsDir), client.getPostgresURL())
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - newMigrate - Error creating migrate instance")
+		return nil, err
+	}
+	return m, nil
+}
+
+func (client *BunPostgresDatabaseClient) migrationLockKey() int64 {
+	return AdvisoryLockKey("migrations:" + client.config.Name)
+}
+
+func (client *BunPostgresDatabaseClient) migrationLockTimeout() time.Duration {
+	timeout, err := time.ParseDuration(client.config.MigrationLockTimeout)
+	if err != nil {
+		return defaultMigrationLockTimeout
+	}
+	return timeout
+}
+
+func (client *BunPostgresDatabaseClient) migrationDryRun() bool {
+	dryRun, _ := strconv.ParseBool(client.config.MigrationDryRun)
+	return dryRun
+}
+
+func (client *BunPostgresDatabaseClient) migrationLintMode() string {
+	switch client.config.MigrationLint {
+	case MigrationLintOff, MigrationLintFail:
+		return client.config.MigrationLint
+	default:
+		return MigrationLintWarn
+	}
+}
+
+func (client *BunPostgresDatabaseClient) migrationDeprecationWindow() time.Duration {
+	window, err := time.ParseDuration(client.config.MigrationDeprecationWindow)
+	if err != nil {
+		return defaultMigrationDeprecationWindow
+	}
+	return window
+}
+
+// MigrationVersion returns the currently applied migration version, 0 if none was applied.
+func (client *BunPostgresDatabaseClient) MigrationVersion() (uint, bool, error) {
+	m, err := client.newMigrate()
+	if err != nil {
+		return 0, false, err
+	}
+	defer m.Close()
+
+	version, dirty, err := m.Version()
+	if err == migrate.ErrNilVersion {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// CachedMigrationVersion is MigrationVersion read at most once a minute, for
+// callers polling it like /sys/info or the metrics.
+func (client *BunPostgresDatabaseClient) CachedMigrationVersion() (uint, bool, error) {
+	cached := &client.migrationVersion
+	cached.mutex.Lock()
+	defer cached.mutex.Unlock()
+
+	if time.Since(cached.checkedAt) > migrationVersionTTL {
+		cached.version, cached.dirty, cached.err = client.MigrationVersion()
+		cached.checkedAt = time.Now()
+	}
+	return cached.version, cached.dirty, cached.err
+}
+
+// PendingMigrations lists the up migrations in MigrationsDir newer than the applied version.
+func (client *BunPostgresDatabaseClient) PendingMigrations() ([]PendingMigration, error) {
+	version, _, err := client.MigrationVersion()
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - PendingMigrations - Error reading migration version")
+		return nil, err
+	}
+
+	files, err := os.ReadDir(client.MigrationsDir)
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - PendingMigrations - Error reading migrations folder")
+		return nil, err
+	}
+
+	pending := make([]PendingMigration, 0)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		migration, err := source.Parse(file.Name())
+		if err != nil || migration.Direction != source.Up || migration.Version <= version {
+			continue
+		}
+		pending = append(pending, PendingMigration{
+			Version:    migration.Version,
+			Identifier: migration.Identifier,
+			File:       filepath.Join(client.MigrationsDir, file.Name()),
+		})
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Version < pending[j].Version
+	})
+	return pending, nil
+}
+
+// MigrateDryRun prints the pending migrations without applying them.
+func (client *BunPostgresDatabaseClient) MigrateDryRun() ([]PendingMigration, error) {
+	pending, err := client.PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pending) == 0 {
+		logger.Component(logComponent).Info().Msg("[POSTGRES CLIENT] - MigrateDryRun - No pending migrations")
+	}
+	for _, migration := range pending {
+		logger.Component(logComponent).Info().
+			Uint("version", migration.Version).
+			Str("file", migration.File).
+			Msg("[POSTGRES CLIENT] - MigrateDryRun - Pending migration")
+	}
+
+	_, err = client.LintPendingMigrations()
+	return pending, err
+}
+
+// LintPendingMigrations runs the migration linter over the pending migrations.
+// Depending on MigrationLint the issues are only logged or returned as an error.
+func (client *BunPostgresDatabaseClient) LintPendingMigrations() ([]MigrationLintIssue, error) {
+	mode := client.migrationLintMode()
+	if mode == MigrationLintOff {
+		return nil, nil
+	}
+
+	pending, err := client.PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]MigrationLintIssue, 0)
+	for _, migration := range pending {
+		content, err := os.ReadFile(migration.File)
+		if err != nil {
+			logger.Component(logComponent).Error().Err(err).Str("file", migration.File).Msg("[POSTGRES CLIENT] - LintPendingMigrations - Error reading migration")
+			return nil, err
+		}
+		issues = append(issues, LintMigration(filepath.Base(migration.File), string(content), client.migrationDeprecationWindow(), time.Now())...)
+	}
+
+	for _, issue := range issues {
+		logger.Component(logComponent).Warn().
+			Str("file", issue.File).
+			Int("line", issue.Line).
+			Str("rule", string(issue.Rule)).
+			Str("statement", issue.Statement).
+			Msgf("[POSTGRES CLIENT] - LintPendingMigrations - %s", issue.Message)
+	}
+
+	if mode == MigrationLintFail && len(issues) > 0 {
+		return issues, fmt.Errorf("%d risky statement(s) found in pending migrations.", len(issues))
+	}
+	return issues, nil
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/events"
+	"github.com/uptrace/bun"
+)
+
+var changeEventSuffixes = map[ChangeAction]string{
+	ChangeActionCreate: "created",
+	ChangeActionUpdate: "updated",
+	ChangeActionDelete: "deleted",
+}
+
+// PublishEvent stores an event in the outbox table. Called with the context
+// of a transaction, the event is only stored if the transaction commits.
+// The outbox relay delivers it afterwards.
+func (client *BunPostgresDatabaseClient) PublishEvent(ctx context.Context, event events.Event) error {
+	return publishEvent(ctx, client.GetDB(ctx), event)
+}
+
+func publishEvent(ctx context.Context, db bun.IDB, event events.Event) error {
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("eventType", event.Type).Msg("[OUTBOX] - PublishEvent - Error marshalling payload")
+		return err
+	}
+
+	message := events.OutboxMessage{
+		EventType:     event.Type,
+		AggregateType: event.AggregateType,
+		AggregateId:   event.AggregateId,
+		Payload:       payload,
+		Headers:       event.Headers,
+	}
+
+	_, err = db.NewInsert().Model(&message).Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("eventType", event.Type).Msg("[OUTBOX] - PublishEvent - Error inserting outbox message")
+		return errors.NewUnkownDatabaseError(err)
+	}
+	return nil
+}
+
+type outboxHook struct {
+	ownerId func(entity interface{}) *string
+}
+
+// WithOutbox publishes an event for every write of the repository, e.g.
+// `rooms.created`, atomically with the write. The payload is the entity and
+// the owner of the entity goes in the `userId` header.
+func (r *PostgresRepository[M]) WithOutbox() *PostgresRepository[M] {
+	return r.AddWriteHook(&outboxHook{ownerId: r.ownerId})
+}
+
+func (hook *outboxHook) AfterWrite(ctx *gin.Context, db bun.IDB, change Change) error {
+	payload := change.After
+	if payload == nil {
+		payload = change.Before
+	}
+
+	event := events.Event{
+		Type:          fmt.Sprintf("%s.%s", change.EntityType, changeEventSuffixes[change.Action]),
+		AggregateType: change.EntityType,
+		AggregateId:   change.EntityId,
+		Payload:       payload,
+	}
+	if owner := hook.ownerId(payload); owner != nil {
+		event.Headers = map[string]string{events.HeaderUserId: *owner}
+	}
+	return publishEvent(ctx, db, event)
+}
+
+// CreateOutboxTable creates the outbox table if it doesn't exist yet.
+func (client *BunPostgresDatabaseClient) CreateOutboxTable(ctx context.Context) error {
+	_, err := client.DB.NewCreateTable().Model((*events.OutboxMessage)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - CreateOutboxTable - Error creating table")
+		return err
+	}
+
+	_, err = client.DB.NewCreateIndex().
+		Model((*events.OutboxMessage)(nil)).
+		Index("outbox_pending_idx").
+		IfNotExists().
+		Column("next_attempt_at").
+		Where("sent_at IS NULL AND failed_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - CreateOutboxTable - Error creating index")
+	}
+	return err
+}
@@ -6,15 +6,18 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"reflect"
 	"strconv"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ginerator/base/config"
 	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/redact"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
-	"github.com/rs/zerolog/log"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
@@ -23,12 +26,23 @@ import (
 
 const (
 	TxContextKey string = "db-tx-key"
+	logComponent        = "repositories"
 )
 
 type BunPostgresDatabaseClient struct {
 	DB            *bun.DB
 	config        *config.DbConfig
 	MigrationsDir string
+
+	models      map[reflect.Type]struct{}
+	modelsMutex sync.Mutex
+
+	changeFeed     *ChangeFeed
+	changeFeedOnce sync.Once
+
+	slowQueries *slowQueryHook
+
+	migrationVersion cachedMigrationVersion
 }
 
 func (client *BunPostgresDatabaseClient) getPostgresURL() string {
@@ -38,14 +52,15 @@ func (client *BunPostgresDatabaseClient) getPostgresURL() string {
 func NewBunPostgresDatabaseClient(config *config.DbConfig, migrationsDir string) *BunPostgresDatabaseClient {
 	_, err := os.Stat(migrationsDir)
 	if err != nil {
-		log.Info().Msg(fmt.Sprintf("[POSTGRES CLIENT] - New - Migration folder: %s doesn't exist.", migrationsDir))
+		logger.Component(logComponent).Info().Msg(fmt.Sprintf("[POSTGRES CLIENT] - New - Migration folder: %s doesn't exist.", migrationsDir))
 	}
 	client := &BunPostgresDatabaseClient{
 		MigrationsDir: migrationsDir,
+		models:        make(map[reflect.Type]struct{}),
 	}
 	client.config = config
 	client.Connect()
-	log.Info().Msg("Database client initialized.")
+	logger.Component(logComponent).Info().Msg("Database client initialized.")
 	return client
 }
 
@@ -53,53 +68,96 @@ func (client *BunPostgresDatabaseClient) Connect() error {
 	maxConnections, _ := strconv.Atoi(client.config.MaxOpenConns)
 	maxIdleConnections, _ := strconv.Atoi(client.config.MaxIdleConns)
 	connectionString := client.getPostgresURL()
-	log.Info().Msgf("Connecting to database: %s", connectionString)
+	logger.Component(logComponent).Info().Msgf("Connecting to database: %s", redact.DSN(connectionString))
 	sqldb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(connectionString)))
 	sqldb.SetMaxOpenConns(maxConnections)
 	sqldb.SetMaxIdleConns(maxIdleConnections)
 	client.DB = bun.NewDB(sqldb, pgdialect.New())
+	// Has to run before bunotel, which reads the span from the context it passes on
+	client.DB.AddQueryHook(requestContextHook{})
 	client.DB.AddQueryHook(bunotel.NewQueryHook(bunotel.WithDBName(client.config.Name)))
+	if client.slowQueryThreshold() > 0 {
+		// Kept across reconnections, so the stats aren't lost
+		if client.slowQueries == nil {
+			client.slowQueries = newSlowQueryHook(client)
+		}
+		client.DB.AddQueryHook(client.slowQueries)
+	}
 	err := client.DB.Ping()
 	if err != nil {
-		log.Error().Err(err).Msg("[POSTGRES CLIENT] - Connect - Error connecting")
+		logger.Component(logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - Connect - Error connecting")
 	}
 	return err
 }
 
 func (client *BunPostgresDatabaseClient) MigrateUp() {
-	m, err := migrate.New(fmt.Sprintf("file://%s", client.MigrationsDir), client.getPostgresURL())
-	err = m.Up()
+	if client.migrationDryRun() {
+		_, err := client.MigrateDryRun()
+		if err != nil {
+			logger.Component(logComponent).Panic().Err(err).Msg("[POSTGRES CLIENT] - MigrateUp - Error running migrations dry run")
+		}
+		return
+	}
+
+	err := client.WithAdvisoryLock(context.Background(), client.migrationLockKey(), client.migrationLockTimeout(), func() error {
+		if _, err := client.LintPendingMigrations(); err != nil {
+			return err
+		}
+
+		m, err := client.newMigrate()
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+
+		return m.Up()
+	})
 	if err != nil && err != migrate.ErrNoChange {
-		log.Panic().Err(err).Msg("[POSTGRES CLIENT] - Connect - Error running migrations")
+		logger.Component(logComponent).Panic().Err(err).Msg("[POSTGRES CLIENT] - Connect - Error running migrations")
 	}
 }
 
 func (client *BunPostgresDatabaseClient) MigrateDown() {
-	m, err := migrate.New(fmt.Sprintf("file://%s", client.MigrationsDir), client.getPostgresURL())
-	err = m.Down()
-	log.Info().Msg("MigrateDown: Applying migration")
+	err := client.WithAdvisoryLock(context.Background(), client.migrationLockKey(), client.migrationLockTimeout(), func() error {
+		m, err := client.newMigrate()
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+
+		logger.Component(logComponent).Info().Msg("MigrateDown: Applying migration")
+		return m.Down()
+	})
 	if err != nil && err != migrate.ErrNoChange {
-		log.Panic().Err(err).Msg("[POSTGRES CLIENT] - Connect - Error running migrations down")
+		logger.Component(logComponent).Panic().Err(err).Msg("[POSTGRES CLIENT] - Connect - Error running migrations down")
 	}
 }
 
 func (client *BunPostgresDatabaseClient) IsConnected() (bool, error) {
 	err := client.DB.Ping()
 	if err != nil {
-		log.Error().Err(err).Msg("[POSTGRES CLIENT] - IsConnected - Checking connection open")
+		logger.Component(logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - IsConnected - Checking connection open")
 		return false, err
 	}
 	return true, nil
 }
 
+// Name returns the name of the database the client is connected to.
+func (client *BunPostgresDatabaseClient) Name() string {
+	return client.config.Name
+}
+
 func (client *BunPostgresDatabaseClient) Close() {
+	if client.changeFeed != nil {
+		client.changeFeed.Close()
+	}
 	client.DB.Close()
 }
 
 func (repo *BunPostgresDatabaseClient) BeginTransaction(ctx *gin.Context) (context.Context, error) {
 	tx, err := repo.DB.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		log.Error().Err(err).Msg("[POSTGRES CLIENT] - BeginTransaction - Could not begin transaction")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - BeginTransaction - Could not begin transaction")
 		return ctx, err
 	}
 
@@ -109,7 +167,7 @@ func (repo *BunPostgresDatabaseClient) BeginTransaction(ctx *gin.Context) (conte
 func (repo *BunPostgresDatabaseClient) ResolveTransaction(ctx *gin.Context, err error) error {
 	tx := repo.getTx(ctx)
 	if tx == nil {
-		log.Error().Err(err).Msg("[POSTGRES CLIENT] - ResolveTransaction - Transaction is null")
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - ResolveTransaction - Transaction is null")
 		return errors.NewUnkownDatabaseError(fmt.Errorf("null transaction"))
 	}
 
@@ -117,11 +175,11 @@ func (repo *BunPostgresDatabaseClient) ResolveTransaction(ctx *gin.Context, err
 		return tx.Commit()
 	}
 
-	log.Error().Err(err).Msg("[POSTGRES CLIENT] - ResolveTransaction - Transaction rolledback")
+	logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - ResolveTransaction - Transaction rolledback")
 	return tx.Rollback()
 }
 
-func (repo *BunPostgresDatabaseClient) getTx(ctx *gin.Context) *bun.Tx {
+func (repo *BunPostgresDatabaseClient) getTx(ctx context.Context) *bun.Tx {
 	value := ctx.Value(TxContextKey)
 	if value == nil {
 		return nil
@@ -131,7 +189,8 @@ func (repo *BunPostgresDatabaseClient) getTx(ctx *gin.Context) *bun.Tx {
 	return &tx
 }
 
-func (repo *BunPostgresDatabaseClient) getDB(ctx *gin.Context) bun.IDB {
+// GetDB returns the transaction stored in the context, or the database if there's none.
+func (repo *BunPostgresDatabaseClient) GetDB(ctx context.Context) bun.IDB {
 	tx := repo.getTx(ctx)
 	if tx == nil {
 		return repo.DB
@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+type routeContextKey struct{}
+
+// requestContextHook runs queries given a gin context with the context of
+// its request instead. gin only looks values up in the request context with
+// ContextWithFallback, so bunotel wouldn't find the span of the request and
+// its query spans would be orphaned. Cancellation isn't inherited, queries
+// keep running when clients disconnect as they always did. The route of the
+// request is kept for the slow query log.
+type requestContextHook struct{}
+
+func (hook requestContextHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	if ginCtx, ok := ctx.(*gin.Context); ok && ginCtx.Request != nil {
+		requestCtx := context.WithoutCancel(ginCtx.Request.Context())
+		return context.WithValue(requestCtx, routeContextKey{}, ginCtx.FullPath())
+	}
+	return ctx
+}
+
+func (hook requestContextHook) AfterQuery(context.Context, *bun.QueryEvent) {}
+
+// queryRoute returns the route of the request that ran a query, if any.
+func queryRoute(ctx context.Context) string {
+	route, _ := ctx.Value(routeContextKey{}).(string)
+	return route
+}
@@ -0,0 +1,275 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/utils"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+type SchemaProblem string
+
+const (
+	SchemaProblemMissingTable  SchemaProblem = "MISSING_TABLE"
+	SchemaProblemMissingColumn SchemaProblem = "MISSING_COLUMN"
+	SchemaProblemTypeMismatch  SchemaProblem = "TYPE_MISMATCH"
+	SchemaProblemNullable      SchemaProblem = "NULLABILITY_MISMATCH"
+)
+
+type SchemaIssue struct {
+	Table    string        `json:"table"`
+	Column   string        `json:"column,omitempty"`
+	Problem  SchemaProblem `json:"problem"`
+	Expected string        `json:"expected,omitempty"`
+	Actual   string        `json:"actual,omitempty"`
+}
+
+type SchemaReport struct {
+	Tables []string      `json:"tables"`
+	Issues []SchemaIssue `json:"issues"`
+}
+
+func (report SchemaReport) IsValid() bool {
+	return len(report.Issues) == 0
+}
+
+type informationSchemaColumn struct {
+	Schema     string  `bun:"table_schema"`
+	Table      string  `bun:"table_name"`
+	Name       string  `bun:"column_name"`
+	DataType   string  `bun:"data_type"`
+	UdtName    string  `bun:"udt_name"`
+	IsNullable string  `bun:"is_nullable"`
+	Default    *string `bun:"column_default"`
+}
+
+var sqlTypeLength = regexp.MustCompile(`\s*\(.*\)`)
+
+var sqlTypeAliases = map[string]string{
+	"VARCHAR":                     "CHARACTER VARYING",
+	"CHAR":                        "CHARACTER",
+	"BPCHAR":                      "CHARACTER",
+	"TIMESTAMP WITH TIME ZONE":    "TIMESTAMPTZ",
+	"TIMESTAMP WITHOUT TIME ZONE": "TIMESTAMP",
+	"TIME WITHOUT TIME ZONE":      "TIME",
+	"TIME WITH TIME ZONE":         "TIMETZ",
+	"INT":                         "INTEGER",
+	"INT4":                        "INTEGER",
+	"SERIAL":                      "INTEGER",
+	"INT8":                        "BIGINT",
+	"BIGSERIAL":                   "BIGINT",
+	"INT2":                        "SMALLINT",
+	"SMALLSERIAL":                 "SMALLINT",
+	"BOOL":                        "BOOLEAN",
+	"FLOAT8":                      "DOUBLE PRECISION",
+	"FLOAT4":                      "REAL",
+	"DECIMAL":                     "NUMERIC",
+}
+
+// RegisterModels adds bun models to the set verified by SchemaCheck. Models
+// used with NewPostgresRepository are registered automatically.
+func (client *BunPostgresDatabaseClient) RegisterModels(models ...interface{}) {
+	client.modelsMutex.Lock()
+	defer client.modelsMutex.Unlock()
+
+	for _, model := range models {
+		modelType := reflect.TypeOf(model)
+		if modelType.Kind() == reflect.Ptr {
+			modelType = modelType.Elem()
+		}
+		if modelType.Kind() != reflect.Struct {
+			continue
+		}
+		if _, exists := client.models[modelType]; !exists {
+			client.models[modelType] = struct{}{}
+		}
+	}
+}
+
+func (client *BunPostgresDatabaseClient) registeredModelTables() []*schema.Table {
+	client.modelsMutex.Lock()
+	defer client.modelsMutex.Unlock()
+
+	tables := make([]*schema.Table, 0, len(client.models))
+	for modelType := range client.models {
+		tables = append(tables, client.DB.Table(modelType))
+	}
+	return tables
+}
+
+// SchemaCheck compares the tables, columns, types and nullability of the
+// registered bun models against information_schema. Passing models checks
+// those instead of the registered ones.
+func (client *BunPostgresDatabaseClient) SchemaCheck(ctx context.Context, models ...interface{}) (SchemaReport, error) {
+	tables := client.registeredModelTables()
+	if len(models) > 0 {
+		tables = make([]*schema.Table, 0, len(models))
+		for _, model := range models {
+			tables = append(tables, client.DB.Table(reflect.TypeOf(model)))
+		}
+	}
+
+	report := SchemaReport{
+		Tables: make([]string, 0, len(tables)),
+		Issues: make([]SchemaIssue, 0),
+	}
+	if len(tables) == 0 {
+		return report, nil
+	}
+
+	tableNames := make([]string, 0, len(tables))
+	for _, table := range tables {
+		tableNames = append(tableNames, table.Name)
+		report.Tables = append(report.Tables, tableSchema(table)+"."+table.Name)
+	}
+
+	columns := make([]informationSchemaColumn, 0)
+	err := client.DB.NewRaw(
+		"SELECT table_schema, table_name, column_name, data_type, udt_name, is_nullable, column_default FROM information_schema.columns WHERE table_name IN (?)",
+		bun.In(tableNames),
+	).Scan(ctx, &columns)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[POSTGRES CLIENT] - SchemaCheck - Error reading information_schema")
+		return report, err
+	}
+
+	liveColumns := make(map[string]map[string]informationSchemaColumn)
+	for _, column := range columns {
+		key := column.Schema + "." + column.Table
+		if _, exists := liveColumns[key]; !exists {
+			liveColumns[key] = make(map[string]informationSchemaColumn)
+		}
+		liveColumns[key][column.Name] = column
+	}
+
+	for _, table := range tables {
+		key := tableSchema(table) + "." + table.Name
+		tableColumns, exists := liveColumns[key]
+		if !exists {
+			report.Issues = append(report.Issues, SchemaIssue{Table: key, Problem: SchemaProblemMissingTable})
+			continue
+		}
+
+		for _, field := range table.Fields {
+			report.Issues = append(report.Issues, compareColumn(key, field, tableColumns)...)
+		}
+	}
+
+	for _, issue := range report.Issues {
+		logger.For(ctx, logComponent).Warn().
+			Str("table", issue.Table).
+			Str("column", issue.Column).
+			Str("expected", issue.Expected).
+			Str("actual", issue.Actual).
+			Msgf("[POSTGRES CLIENT] - SchemaCheck - %s", issue.Problem)
+	}
+
+	return report, nil
+}
+
+func compareColumn(table string, field *schema.Field, columns map[string]informationSchemaColumn) []SchemaIssue {
+	column, exists := columns[field.Name]
+	if !exists {
+		return []SchemaIssue{{
+			Table:    table,
+			Column:   field.Name,
+			Problem:  SchemaProblemMissingColumn,
+			Expected: field.CreateTableSQLType,
+		}}
+	}
+
+	issues := make([]SchemaIssue, 0)
+
+	expectedType, actualType := normalizeSQLType(field.CreateTableSQLType), normalizeColumnType(column)
+	if expectedType != actualType && !(field.UserSQLType == "" && isTextType(expectedType) && isTextType(actualType)) {
+		issues = append(issues, SchemaIssue{
+			Table:    table,
+			Column:   field.Name,
+			Problem:  SchemaProblemTypeMismatch,
+			Expected: expectedType,
+			Actual:   actualType,
+		})
+	}
+
+	isNullable := column.IsNullable == "YES"
+	modelNotNull := field.NotNull || field.IsPK
+	// The model may write NULL into a NOT NULL column which has no default to fall back to
+	modelWritesNull := !modelNotNull && (field.IsPtr || field.NullZero) && column.Default == nil
+	if (modelNotNull && isNullable) || (!isNullable && modelWritesNull) {
+		issues = append(issues, SchemaIssue{
+			Table:    table,
+			Column:   field.Name,
+			Problem:  SchemaProblemNullable,
+			Expected: nullability(!modelNotNull),
+			Actual:   nullability(isNullable),
+		})
+	}
+
+	return issues
+}
+
+func tableSchema(table *schema.Table) string {
+	if table.Schema != "" {
+		return table.Schema
+	}
+	return "public"
+}
+
+func normalizeSQLType(sqlType string) string {
+	sqlType = strings.ToUpper(strings.TrimSpace(sqlTypeLength.ReplaceAllString(sqlType, "")))
+	if strings.HasSuffix(sqlType, "[]") {
+		return "ARRAY"
+	}
+	if alias, exists := sqlTypeAliases[sqlType]; exists {
+		return alias
+	}
+	return sqlType
+}
+
+// isTextType tells whether a normalized type holds strings. bun creates the
+// strings without a `type:` tag as VARCHAR, while migrations often use TEXT.
+func isTextType(sqlType string) bool {
+	return sqlType == "TEXT" || sqlType == "CHARACTER VARYING"
+}
+
+func normalizeColumnType(column informationSchemaColumn) string {
+	if column.DataType == "USER-DEFINED" {
+		return normalizeSQLType(column.UdtName)
+	}
+	return normalizeSQLType(column.DataType)
+}
+
+func nullability(isNullable bool) string {
+	if isNullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+type schemaCheckMonitor struct {
+	client *BunPostgresDatabaseClient
+}
+
+// SchemaMonitor exposes SchemaCheck as a Monitorable dependency, so it can
+// be added to the AppStateManager and reported in /sys/health.
+func (client *BunPostgresDatabaseClient) SchemaMonitor() utils.Monitorable {
+	return &schemaCheckMonitor{client: client}
+}
+
+func (monitor *schemaCheckMonitor) IsConnected() (bool, error) {
+	report, err := monitor.client.SchemaCheck(context.Background())
+	if err != nil {
+		return false, err
+	}
+	if !report.IsValid() {
+		issue := report.Issues[0]
+		return false, fmt.Errorf("Schema drift detected in %d column(s), e.g. %s %s.%s.", len(report.Issues), issue.Problem, issue.Table, issue.Column)
+	}
+	return true, nil
+}
@@ -0,0 +1,55 @@
+//go:build unit
+
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun/schema"
+)
+
+func TestCompareColumnType(t *testing.T) {
+	type testData struct {
+		name     string
+		field    *schema.Field
+		dataType string
+		issues   int
+	}
+
+	tests := []testData{
+		{
+			name:     "Default string type on a text column",
+			field:    &schema.Field{Name: "name", CreateTableSQLType: "varchar"},
+			dataType: "text",
+			issues:   0,
+		},
+		{
+			name:     "Explicit varchar on a text column",
+			field:    &schema.Field{Name: "name", CreateTableSQLType: "varchar(64)", UserSQLType: "varchar(64)"},
+			dataType: "text",
+			issues:   1,
+		},
+		{
+			name:     "Timestamp aliases",
+			field:    &schema.Field{Name: "created_at", CreateTableSQLType: "timestamptz"},
+			dataType: "timestamp with time zone",
+			issues:   0,
+		},
+		{
+			name:     "Different types",
+			field:    &schema.Field{Name: "count", CreateTableSQLType: "bigint"},
+			dataType: "text",
+			issues:   1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			columns := map[string]informationSchemaColumn{
+				test.field.Name: {Name: test.field.Name, DataType: test.dataType, IsNullable: "YES"},
+			}
+			assert.Len(t, compareColumn("rooms", test.field, columns), test.issues)
+		})
+	}
+}
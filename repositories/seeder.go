@@ -0,0 +1,398 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ginerator/base/logger"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// Fixture references look like `$users.alice` or `$users.alice.email` and
+// resolve to the primary key (or the given column) of another fixture row.
+var seedReference = regexp.MustCompile(`^\$([A-Za-z0-9_]+)\.([A-Za-z0-9_\-]+)(?:\.([A-Za-z0-9_]+))?$`)
+
+type seedFixtureSet struct {
+	table           *schema.Table
+	conflictColumns []string
+}
+
+type seedRow struct {
+	set    string
+	key    string
+	values map[string]interface{}
+}
+
+func (row seedRow) id() string {
+	return row.set + "." + row.key
+}
+
+// Seeder loads YAML/JSON fixture files into the tables of registered bun
+// models. Files in the seeds folder are shared by every environment, files in
+// the `<seeds folder>/<env>` subfolder only apply to that environment and
+// override shared rows with the same key.
+//
+//	users:
+//	  alice:
+//	    id: 1
+//	    email: alice@example.com
+//	items:
+//	  backpack:
+//	    id: 1
+//	    owner_id: $users.alice
+type Seeder struct {
+	client   *BunPostgresDatabaseClient
+	dir      string
+	env      string
+	fixtures map[string]seedFixtureSet
+}
+
+func NewSeeder(client *BunPostgresDatabaseClient, seedsDir string, env string) *Seeder {
+	_, err := os.Stat(seedsDir)
+	if err != nil {
+		logger.Component(logComponent).Info().Msg(fmt.Sprintf("[SEEDER] - New - Seeds folder: %s doesn't exist.", seedsDir))
+	}
+	return &Seeder{
+		client:   client,
+		dir:      seedsDir,
+		env:      env,
+		fixtures: make(map[string]seedFixtureSet),
+	}
+}
+
+// Register maps a fixture set name to a bun model. Rows are upserted on the
+// conflict columns, which default to the model primary keys, so every row
+// has to set them.
+func (seeder *Seeder) Register(name string, model interface{}, conflictColumns ...string) *Seeder {
+	table := seeder.client.DB.Table(reflect.TypeOf(model))
+	if len(conflictColumns) == 0 {
+		for _, pk := range table.PKs {
+			conflictColumns = append(conflictColumns, pk.Name)
+		}
+	}
+	seeder.fixtures[name] = seedFixtureSet{
+		table:           table,
+		conflictColumns: conflictColumns,
+	}
+	return seeder
+}
+
+// Seed upserts every fixture of the current environment in one transaction.
+func (seeder *Seeder) Seed(ctx context.Context) error {
+	return seeder.client.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return seeder.seed(ctx, tx, nil)
+	})
+}
+
+// Reset truncates the tables of the given fixture sets and seeds them again,
+// all inside one transaction. It's meant to put tests back to a known state.
+// Tables aren't truncated in cascade: sets whose tables reference the reset
+// ones have to be reset with them, Postgres refuses the truncate otherwise.
+func (seeder *Seeder) Reset(ctx context.Context, names ...string) error {
+	tables := make([]string, 0, len(names))
+	only := make(map[string]bool, len(names))
+	for _, name := range names {
+		fixtureSet, exists := seeder.fixtures[name]
+		if !exists {
+			return fmt.Errorf("Fixture set '%s' is not registered.", name)
+		}
+		tables = append(tables, fixtureSet.table.Name)
+		only[name] = true
+	}
+
+	return seeder.client.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if len(tables) > 0 {
+			_, err := tx.NewTruncateTable().Table(tables...).Exec(ctx)
+			if err != nil {
+				logger.For(ctx, logComponent).Error().Err(err).Strs("tables", tables).Msg("[SEEDER] - Reset - Error truncating tables")
+				return fmt.Errorf("Truncating %s, reset the fixture sets referencing them too: %w", strings.Join(tables, ", "), err)
+			}
+		}
+		return seeder.seed(ctx, tx, only)
+	})
+}
+
+func (seeder *Seeder) seed(ctx context.Context, tx bun.Tx, only map[string]bool) error {
+	rows, err := seeder.loadRows()
+	if err != nil {
+		return err
+	}
+
+	ordered, err := sortSeedRows(rows)
+	if err != nil {
+		return err
+	}
+
+	inserted := make(map[string]map[string]interface{}, len(ordered))
+	for _, row := range ordered {
+		if only != nil && !only[row.set] {
+			// Rows of other sets may still be referenced, so they're read back as they are
+			values, err := seeder.selectRow(ctx, tx, row, inserted)
+			if err != nil {
+				return err
+			}
+			inserted[row.id()] = values
+			continue
+		}
+
+		values, err := seeder.upsertRow(ctx, tx, row, inserted)
+		if err != nil {
+			return err
+		}
+		inserted[row.id()] = values
+	}
+
+	logger.For(ctx, logComponent).Info().Int("rows", len(ordered)).Str("env", seeder.env).Msg("[SEEDER] - Seed - Fixtures loaded")
+	return nil
+}
+
+func (seeder *Seeder) resolveRow(row seedRow, inserted map[string]map[string]interface{}) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(row.values))
+	for column, value := range row.values {
+		resolved, err := resolveSeedValue(value, inserted, seeder.fixtures)
+		if err != nil {
+			return nil, fmt.Errorf("Fixture %s, column %s: %w", row.id(), column, err)
+		}
+		values[column] = resolved
+	}
+	return values, nil
+}
+
+func (seeder *Seeder) upsertRow(ctx context.Context, tx bun.Tx, row seedRow, inserted map[string]map[string]interface{}) (map[string]interface{}, error) {
+	fixtureSet := seeder.fixtures[row.set]
+	values, err := seeder.resolveRow(row, inserted)
+	if err != nil {
+		return nil, err
+	}
+
+	query := tx.NewInsert().
+		Model(&values).
+		ModelTableExpr("?", fixtureSet.table.SQLName).
+		On(fmt.Sprintf("CONFLICT (%s) DO UPDATE", strings.Join(fixtureSet.conflictColumns, ", "))).
+		Returning("*")
+	for column := range values {
+		query.Set("? = EXCLUDED.?", bun.Ident(column), bun.Ident(column))
+	}
+
+	result := make(map[string]interface{})
+	if err := query.Scan(ctx, &result); err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("fixture", row.id()).Msg("[SEEDER] - Seed - Error upserting fixture")
+		return nil, err
+	}
+	return result, nil
+}
+
+func (seeder *Seeder) selectRow(ctx context.Context, tx bun.Tx, row seedRow, inserted map[string]map[string]interface{}) (map[string]interface{}, error) {
+	fixtureSet := seeder.fixtures[row.set]
+	values, err := seeder.resolveRow(row, inserted)
+	if err != nil {
+		return nil, err
+	}
+
+	query := tx.NewSelect().TableExpr("?", fixtureSet.table.SQLName)
+	for _, column := range fixtureSet.conflictColumns {
+		query.Where("? = ?", bun.Ident(column), values[column])
+	}
+
+	result := make(map[string]interface{})
+	if err := query.Scan(ctx, &result); err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("fixture", row.id()).Msg("[SEEDER] - Seed - Error reading fixture")
+		return nil, err
+	}
+	return result, nil
+}
+
+func (seeder *Seeder) loadRows() ([]seedRow, error) {
+	files, err := seedFiles(seeder.dir)
+	if err != nil {
+		return nil, err
+	}
+	envFiles, err := seedFiles(filepath.Join(seeder.dir, seeder.env))
+	if err != nil {
+		return nil, err
+	}
+
+	rowsById := make(map[string]seedRow)
+	for _, file := range append(files, envFiles...) {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			logger.Component(logComponent).Error().Err(err).Str("file", file).Msg("[SEEDER] - Seed - Error reading fixture file")
+			return nil, err
+		}
+
+		sets := make(map[string]map[string]map[string]interface{})
+		if err := yaml.Unmarshal(content, &sets); err != nil {
+			logger.Component(logComponent).Error().Err(err).Str("file", file).Msg("[SEEDER] - Seed - Error parsing fixture file")
+			return nil, err
+		}
+
+		for set, rows := range sets {
+			fixtureSet, exists := seeder.fixtures[set]
+			if !exists {
+				return nil, fmt.Errorf("Fixture set '%s' in %s is not registered.", set, file)
+			}
+			for key, values := range rows {
+				for column := range values {
+					if _, exists := fixtureSet.table.FieldMap[column]; !exists {
+						return nil, fmt.Errorf("Fixture %s.%s in %s has column '%s' which is not part of model %s.", set, key, file, column, fixtureSet.table.TypeName)
+					}
+				}
+				// Without its conflict key a row would be inserted again on every run
+				if !hasColumns(values, fixtureSet.conflictColumns) {
+					return nil, fmt.Errorf("Fixture %s.%s in %s needs the columns %s to be upserted.", set, key, file, strings.Join(fixtureSet.conflictColumns, ", "))
+				}
+				row := seedRow{set: set, key: key, values: values}
+				rowsById[row.id()] = row
+			}
+		}
+	}
+
+	rows := make([]seedRow, 0, len(rowsById))
+	for _, row := range rowsById {
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func seedFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Str("dir", dir).Msg("[SEEDER] - Seed - Error reading seeds folder")
+		return nil, err
+	}
+
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// sortSeedRows orders the rows so every row comes after the rows it references.
+func sortSeedRows(rows []seedRow) ([]seedRow, error) {
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].id() < rows[j].id()
+	})
+
+	rowsById := make(map[string]seedRow, len(rows))
+	for _, row := range rows {
+		rowsById[row.id()] = row
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(rows))
+	ordered := make([]seedRow, 0, len(rows))
+
+	var visit func(row seedRow) error
+	visit = func(row seedRow) error {
+		switch state[row.id()] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("Fixture %s has a circular reference.", row.id())
+		}
+		state[row.id()] = visiting
+
+		columns := make([]string, 0, len(row.values))
+		for column := range row.values {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		for _, column := range columns {
+			match := matchSeedReference(row.values[column])
+			if match == nil {
+				continue
+			}
+			dependency, exists := rowsById[match[1]+"."+match[2]]
+			if !exists {
+				return fmt.Errorf("Fixture %s references %s which doesn't exist.", row.id(), row.values[column])
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+
+		state[row.id()] = visited
+		ordered = append(ordered, row)
+		return nil
+	}
+
+	for _, row := range rows {
+		if err := visit(row); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+func matchSeedReference(value interface{}) []string {
+	str, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	return seedReference.FindStringSubmatch(str)
+}
+
+func resolveSeedValue(value interface{}, inserted map[string]map[string]interface{}, fixtures map[string]seedFixtureSet) (interface{}, error) {
+	if match := matchSeedReference(value); match != nil {
+		row := inserted[match[1]+"."+match[2]]
+		column := match[3]
+		if column == "" {
+			pks := fixtures[match[1]].table.PKs
+			if len(pks) != 1 {
+				return nil, fmt.Errorf("Reference %s needs an explicit column, model has %d primary keys.", value, len(pks))
+			}
+			column = pks[0].Name
+		}
+		resolved, exists := row[column]
+		if !exists {
+			return nil, fmt.Errorf("Reference %s could not be resolved.", value)
+		}
+		return resolved, nil
+	}
+
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(encoded), nil
+	}
+	return value, nil
+}
+
+func hasColumns(values map[string]interface{}, columns []string) bool {
+	if len(columns) == 0 {
+		return false
+	}
+	for _, column := range columns {
+		if _, exists := values[column]; !exists {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+//go:build unit
+
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun/schema"
+)
+
+func TestSortSeedRows(t *testing.T) {
+	type testData struct {
+		name     string
+		rows     []seedRow
+		expected []string
+		err      bool
+	}
+
+	tests := []testData{
+		{
+			name: "references come first",
+			rows: []seedRow{
+				{set: "items", key: "backpack", values: map[string]interface{}{"owner_id": "$users.alice"}},
+				{set: "users", key: "alice", values: map[string]interface{}{"manager_id": "$users.bob.id"}},
+				{set: "users", key: "bob", values: map[string]interface{}{"email": "bob@example.com"}},
+			},
+			expected: []string{"users.bob", "users.alice", "items.backpack"},
+		},
+		{
+			name: "missing reference",
+			rows: []seedRow{
+				{set: "items", key: "backpack", values: map[string]interface{}{"owner_id": "$users.carol"}},
+			},
+			err: true,
+		},
+		{
+			name: "circular reference",
+			rows: []seedRow{
+				{set: "users", key: "alice", values: map[string]interface{}{"manager_id": "$users.bob"}},
+				{set: "users", key: "bob", values: map[string]interface{}{"manager_id": "$users.alice"}},
+			},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ordered, err := sortSeedRows(test.rows)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			ids := make([]string, 0, len(ordered))
+			for _, row := range ordered {
+				ids = append(ids, row.id())
+			}
+			assert.Equal(t, test.expected, ids)
+		})
+	}
+}
+
+func TestResolveSeedValue(t *testing.T) {
+	fixtures := map[string]seedFixtureSet{
+		"users":    {table: &schema.Table{PKs: []*schema.Field{{Name: "id"}}}},
+		"bookings": {table: &schema.Table{PKs: []*schema.Field{{Name: "room_id"}, {Name: "day"}}}},
+	}
+	inserted := map[string]map[string]interface{}{
+		"users.alice":    {"id": int64(7), "email": "alice@example.com"},
+		"bookings.first": {"room_id": int64(1), "day": "2026-10-01"},
+	}
+
+	type testData struct {
+		name     string
+		value    interface{}
+		expected interface{}
+		err      bool
+	}
+
+	tests := []testData{
+		{name: "primary key", value: "$users.alice", expected: int64(7)},
+		{name: "column", value: "$users.alice.email", expected: "alice@example.com"},
+		{name: "plain value", value: "alice", expected: "alice"},
+		{name: "json", value: map[string]interface{}{"theme": "dark"}, expected: `{"theme":"dark"}`},
+		{name: "unknown column", value: "$users.alice.phone", err: true},
+		{name: "composite key without column", value: "$bookings.first", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved, err := resolveSeedValue(test.value, inserted, fixtures)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, resolved)
+		})
+	}
+}
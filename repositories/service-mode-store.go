@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/servicemode"
+	user "github.com/ginerator/base/model/users"
+	"github.com/uptrace/bun"
+)
+
+const defaultServiceModeRefreshInterval = 5 * time.Second
+
+// ServiceModeStore keeps the service mode in the service_modes table and a
+// copy in memory, refreshed in the background so every replica follows a
+// switch within the refresh interval without querying on each request.
+type ServiceModeStore struct {
+	client          *BunPostgresDatabaseClient
+	refreshInterval time.Duration
+	current         atomic.Pointer[servicemode.Mode]
+
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+func NewServiceModeStore(client *BunPostgresDatabaseClient, refreshInterval time.Duration) *ServiceModeStore {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultServiceModeRefreshInterval
+	}
+	store := &ServiceModeStore{client: client, refreshInterval: refreshInterval}
+	store.current.Store(&servicemode.Mode{Id: servicemode.SingletonId})
+	return store
+}
+
+// Mode returns the last known mode. If it can't be read, the service keeps
+// working in the mode it was in.
+func (store *ServiceModeStore) Mode() servicemode.Mode {
+	return *store.current.Load()
+}
+
+// Start loads the mode and refreshes it until Close is called.
+func (store *ServiceModeStore) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	store.cancel = cancel
+	store.refresh(ctx)
+
+	store.done.Add(1)
+	go func() {
+		defer store.done.Done()
+		ticker := time.NewTicker(store.refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				store.refresh(ctx)
+			}
+		}
+	}()
+}
+
+func (store *ServiceModeStore) Close() {
+	if store.cancel == nil {
+		return
+	}
+	store.cancel()
+	store.done.Wait()
+}
+
+func (store *ServiceModeStore) refresh(ctx context.Context) {
+	mode, err := store.Get(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Component(logComponent).Error().Err(err).Msg("[SERVICE MODE] - refresh - Error reading service mode")
+		}
+		return
+	}
+	previous := store.current.Swap(&mode)
+	if previous.Maintenance != mode.Maintenance || previous.ReadOnly != mode.ReadOnly {
+		logger.Component(logComponent).Warn().
+			Bool("maintenance", mode.Maintenance).
+			Bool("readOnly", mode.ReadOnly).
+			Msg("[SERVICE MODE] - refresh - Service mode changed")
+	}
+}
+
+// Get reads the mode from the database, the normal mode if it was never set.
+func (store *ServiceModeStore) Get(ctx context.Context) (servicemode.Mode, error) {
+	mode := servicemode.Mode{Id: servicemode.SingletonId}
+	err := store.client.DB.NewSelect().Model(&mode).WherePK().Scan(ctx)
+	if err == sql.ErrNoRows {
+		return servicemode.Mode{Id: servicemode.SingletonId}, nil
+	}
+	return mode, err
+}
+
+// Update switches the modes set in the request, recording who did it. An
+// empty reason clears it. The row is locked while it's changed, so concurrent
+// updates of different switches don't undo each other.
+func (store *ServiceModeStore) Update(ctx *gin.Context, request servicemode.UpdateModeRequest) (servicemode.Mode, error) {
+	mode := servicemode.Mode{Id: servicemode.SingletonId}
+	err := store.client.DB.RunInTx(ctx, nil, func(txCtx context.Context, tx bun.Tx) error {
+		// The row may not exist yet, there would be nothing to lock
+		if _, err := tx.NewInsert().Model(&servicemode.Mode{Id: servicemode.SingletonId}).On("CONFLICT (id) DO NOTHING").Exec(txCtx); err != nil {
+			return err
+		}
+		if err := tx.NewSelect().Model(&mode).WherePK().For("UPDATE").Scan(txCtx); err != nil {
+			return err
+		}
+
+		if request.Maintenance != nil {
+			mode.Maintenance = *request.Maintenance
+		}
+		if request.ReadOnly != nil {
+			mode.ReadOnly = *request.ReadOnly
+		}
+		if request.Reason != nil {
+			mode.Reason = request.Reason
+			if *request.Reason == "" {
+				mode.Reason = nil
+			}
+		}
+		if request.RetryAfterSeconds != nil {
+			mode.RetryAfterSeconds = *request.RetryAfterSeconds
+		}
+		mode.UpdatedBy = nil
+		if contextUser := user.GetUser(ctx); contextUser != nil {
+			mode.UpdatedBy = contextUser.Actor()
+		}
+		mode.UpdatedAt = time.Now()
+
+		_, err := tx.NewUpdate().Model(&mode).WherePK().Exec(txCtx)
+		return err
+	})
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Msg("[SERVICE MODE] - Update - Error saving service mode")
+		return mode, err
+	}
+
+	// This replica follows right away, the others on their next refresh
+	store.current.Store(&mode)
+	logger.For(ctx, logComponent).Warn().
+		Bool("maintenance", mode.Maintenance).
+		Bool("readOnly", mode.ReadOnly).
+		Msg("[SERVICE MODE] - Update - Service mode changed")
+	return mode, nil
+}
+
+// CreateServiceModeTable creates the service_modes table if it doesn't exist yet.
+func (client *BunPostgresDatabaseClient) CreateServiceModeTable(ctx context.Context) error {
+	_, err := client.DB.NewCreateTable().Model((*servicemode.Mode)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[SERVICE MODE] - CreateServiceModeTable - Error creating table")
+	}
+	return err
+}
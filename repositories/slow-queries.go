@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ginerator/base/logger"
+	"github.com/uptrace/bun"
+)
+
+const (
+	defaultSlowQueryThreshold = 500 * time.Millisecond
+	// slowQueryFingerprints bounds how many distinct queries are tracked, the
+	// least recently seen one is dropped to make room for a new one.
+	slowQueryFingerprints   = 1000
+	slowQueryExplainTimeout = 5 * time.Second
+)
+
+var (
+	queryStringLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+	queryNumber        = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	queryValueList     = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	querySpaces        = regexp.MustCompile(`\s+`)
+	explainableQuery   = regexp.MustCompile(`(?i)^\s*(SELECT|INSERT|UPDATE|DELETE|WITH)\b`)
+)
+
+// SlowQuery aggregates the slow runs of queries with the same fingerprint.
+type SlowQuery struct {
+	Fingerprint   string          `json:"fingerprint"`
+	Count         int64           `json:"count"`
+	TotalDuration time.Duration   `json:"totalDuration"`
+	MaxDuration   time.Duration   `json:"maxDuration"`
+	LastRoute     string          `json:"lastRoute,omitempty"`
+	LastSeenAt    time.Time       `json:"lastSeenAt"`
+	Plan          json.RawMessage `json:"plan,omitempty"`
+}
+
+// NormalizeQuery replaces the literals of a query with placeholders and
+// collapses lists of values, so runs with different arguments share a fingerprint.
+func NormalizeQuery(query string) string {
+	query = queryStringLiteral.ReplaceAllString(query, "?")
+	query = queryNumber.ReplaceAllString(query, "?")
+	query = queryValueList.ReplaceAllString(query, "(...)")
+	return strings.TrimSpace(querySpaces.ReplaceAllString(query, " "))
+}
+
+// NormalizePlan runs the strings of a JSON query plan, e.g. its filters and
+// index conditions, through NormalizeQuery, so the plan doesn't expose the
+// values the query was run with.
+func NormalizePlan(plan json.RawMessage) (json.RawMessage, error) {
+	var node interface{}
+	if err := json.Unmarshal(plan, &node); err != nil {
+		return nil, err
+	}
+	return json.Marshal(normalizePlanNode(node))
+}
+
+func normalizePlanNode(node interface{}) interface{} {
+	switch value := node.(type) {
+	case string:
+		return NormalizeQuery(value)
+	case []interface{}:
+		for i := range value {
+			value[i] = normalizePlanNode(value[i])
+		}
+	case map[string]interface{}:
+		for key := range value {
+			value[key] = normalizePlanNode(value[key])
+		}
+	}
+	return node
+}
+
+type slowQueryHook struct {
+	client      *BunPostgresDatabaseClient
+	threshold   time.Duration
+	explainRate float64
+
+	mutex      sync.Mutex
+	queries    map[string]*SlowQuery
+	explaining atomic.Bool
+}
+
+func (client *BunPostgresDatabaseClient) slowQueryThreshold() time.Duration {
+	threshold, err := time.ParseDuration(client.config.SlowQueryThreshold)
+	if err != nil {
+		return defaultSlowQueryThreshold
+	}
+	return threshold
+}
+
+func (client *BunPostgresDatabaseClient) slowQueryExplainRate() float64 {
+	rate, _ := strconv.ParseFloat(client.config.SlowQueryExplainRate, 64)
+	return rate
+}
+
+func newSlowQueryHook(client *BunPostgresDatabaseClient) *slowQueryHook {
+	return &slowQueryHook{
+		client:      client,
+		threshold:   client.slowQueryThreshold(),
+		explainRate: client.slowQueryExplainRate(),
+		queries:     make(map[string]*SlowQuery),
+	}
+}
+
+func (hook *slowQueryHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (hook *slowQueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	duration := time.Since(event.StartTime)
+	if duration < hook.threshold {
+		return
+	}
+
+	fingerprint := NormalizeQuery(event.Query)
+	route := queryRoute(ctx)
+	rows := int64(-1)
+	if event.Result != nil {
+		if affected, err := event.Result.RowsAffected(); err == nil {
+			rows = affected
+		}
+	}
+
+	logger.For(ctx, logComponent).Warn().
+		Str("query", fingerprint).
+		Dur("duration", duration).
+		Int64("rows", rows).
+		Str("route", route).
+		Msg("[POSTGRES CLIENT] - AfterQuery - Slow query")
+
+	hook.record(fingerprint, duration, route)
+	if hook.explainRate > 0 && rand.Float64() < hook.explainRate && explainableQuery.MatchString(event.Query) {
+		go hook.explain(fingerprint, event.Query)
+	}
+}
+
+func (hook *slowQueryHook) record(fingerprint string, duration time.Duration, route string) {
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+
+	query, exists := hook.queries[fingerprint]
+	if !exists {
+		if len(hook.queries) >= slowQueryFingerprints {
+			hook.evictOldest()
+		}
+		query = &SlowQuery{Fingerprint: fingerprint}
+		hook.queries[fingerprint] = query
+	}
+	query.Count++
+	query.TotalDuration += duration
+	query.MaxDuration = max(query.MaxDuration, duration)
+	query.LastRoute = route
+	query.LastSeenAt = time.Now()
+}
+
+func (hook *slowQueryHook) evictOldest() {
+	var oldest *SlowQuery
+	for _, query := range hook.queries {
+		if oldest == nil || query.LastSeenAt.Before(oldest.LastSeenAt) {
+			oldest = query
+		}
+	}
+	if oldest != nil {
+		delete(hook.queries, oldest.Fingerprint)
+	}
+}
+
+// explain captures the plan of a slow query without running it. Only one
+// plan is captured at a time, so a burst of slow queries doesn't take more
+// connections from a pool that may be exhausted already.
+func (hook *slowQueryHook) explain(fingerprint string, query string) {
+	if !hook.explaining.CompareAndSwap(false, true) {
+		return
+	}
+	defer hook.explaining.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), slowQueryExplainTimeout)
+	defer cancel()
+
+	// The query is already formatted, so it's run as is rather than as a template
+	var plan []json.RawMessage
+	rows, err := hook.client.DB.DB.QueryContext(ctx, "EXPLAIN (ANALYZE off, FORMAT JSON) "+query)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var line json.RawMessage
+			if err = rows.Scan(&line); err != nil {
+				break
+			}
+			plan = append(plan, line)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	if err != nil || len(plan) == 0 {
+		logger.Component(logComponent).Debug().Err(err).Str("query", fingerprint).Msg("[POSTGRES CLIENT] - explain - Error capturing query plan")
+		return
+	}
+	normalizedPlan, err := NormalizePlan(plan[0])
+	if err != nil {
+		logger.Component(logComponent).Debug().Err(err).Str("query", fingerprint).Msg("[POSTGRES CLIENT] - explain - Error normalizing query plan")
+		return
+	}
+
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	if slowQuery, exists := hook.queries[fingerprint]; exists {
+		slowQuery.Plan = normalizedPlan
+	}
+}
+
+func (hook *slowQueryHook) top(limit int) []SlowQuery {
+	hook.mutex.Lock()
+	queries := make([]SlowQuery, 0, len(hook.queries))
+	for _, query := range hook.queries {
+		queries = append(queries, *query)
+	}
+	hook.mutex.Unlock()
+
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].TotalDuration > queries[j].TotalDuration
+	})
+	if limit > 0 && len(queries) > limit {
+		queries = queries[:limit]
+	}
+	return queries
+}
+
+// SlowQueries returns the slow query fingerprints that took the most time in
+// total, at most limit of them. It's empty if the slow query log is disabled.
+func (client *BunPostgresDatabaseClient) SlowQueries(limit int) []SlowQuery {
+	if client.slowQueries == nil {
+		return []SlowQuery{}
+	}
+	return client.slowQueries.top(limit)
+}
@@ -0,0 +1,52 @@
+//go:build unit
+
+package postgres_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	postgres "github.com/ginerator/base/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			"literals",
+			`SELECT "room"."id" FROM "rooms" AS "room" WHERE (name = 'O''Brien') AND (floor > 3.5) LIMIT 10`,
+			`SELECT "room"."id" FROM "rooms" AS "room" WHERE (name = ?) AND (floor > ?) LIMIT ?`,
+		},
+		{
+			"value lists",
+			"SELECT * FROM rooms\n  WHERE id IN ('a', 'b',\n 'c') AND floor IN (1,2)",
+			"SELECT * FROM rooms WHERE id IN (...) AND floor IN (...)",
+		},
+		{
+			"identifiers with digits",
+			`SELECT "table1"."col_2" FROM table1`,
+			`SELECT "table1"."col_2" FROM table1`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, postgres.NormalizeQuery(test.query))
+		})
+	}
+}
+
+func TestNormalizePlan(t *testing.T) {
+	plan := `[{"Plan": {"Node Type": "Index Scan", "Relation Name": "users", "Total Cost": 8.3, "Index Cond": "(email = 'jane@example.com'::text)", "Filter": "(age > 42)"}}]`
+
+	normalized, err := postgres.NormalizePlan(json.RawMessage(plan))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"Plan": {"Node Type": "Index Scan", "Relation Name": "users", "Total Cost": 8.3, "Index Cond": "(email = ?::text)", "Filter": "(age > ?)"}}]`, string(normalized))
+
+	_, err = postgres.NormalizePlan(json.RawMessage("not a plan"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/utils"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+const (
+	ColumnValidFrom = "valid_from"
+	ColumnValidTo   = "valid_to"
+)
+
+type readOptions struct {
+	asOf *time.Time
+}
+
+type ReadOption func(*readOptions)
+
+// AsOf reads the version of versioned entities that was valid at the given time.
+func AsOf(asOf time.Time) ReadOption {
+	return func(options *readOptions) {
+		options.asOf = &asOf
+	}
+}
+
+// Versioned turns the repository into a versioned one: updates and deletes
+// close the current row, setting its valid_to, and append a new version
+// instead of overwriting it. The table primary key has to be (id, valid_from)
+// and the model has to map every column, as versions are copied through it.
+func (r *PostgresRepository[M]) Versioned() *PostgresRepository[M] {
+	r.versioned = true
+	return r
+}
+
+func (r *PostgresRepository[M]) buildReadOptions(ctx *gin.Context, opts []ReadOption) readOptions {
+	options := readOptions{asOf: utils.GetAsOf(ctx)}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// filterVersions keeps the current versions, or the ones valid at asOf.
+func (r *PostgresRepository[M]) filterVersions(query *bun.SelectQuery, options readOptions) {
+	if !r.versioned {
+		return
+	}
+
+	if options.asOf == nil {
+		query.Where("? IS NULL", bun.Ident(ColumnValidTo))
+		return
+	}
+	query.
+		Where("? <= ?", bun.Ident(ColumnValidFrom), *options.asOf).
+		Where("(? IS NULL OR ? > ?)", bun.Ident(ColumnValidTo), bun.Ident(ColumnValidTo), *options.asOf)
+}
+
+func (r *PostgresRepository[M]) currentVersionOnly(query *bun.UpdateQuery) {
+	if r.versioned {
+		query.Where("? IS NULL", bun.Ident(ColumnValidTo))
+	}
+}
+
+func (r *PostgresRepository[M]) setCreateVersionColumns(query *bun.InsertQuery) {
+	if r.versioned {
+		query.Value(ColumnValidFrom, "?", time.Now().UTC())
+		query.Value(ColumnValidTo, "NULL")
+	}
+}
+
+// appendVersion closes the current version of the entity and inserts a copy
+// of it as the new current version, which the write then modifies.
+func (r *PostgresRepository[M]) appendVersion(ctx *gin.Context, db bun.IDB, id uuid.UUID, userId *string) error {
+	if !r.versioned {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	current := new(M)
+	query := db.NewUpdate().
+		Model(current).
+		Set("? = ?", bun.Ident(ColumnValidTo), now).
+		Where("id = ?", id).
+		Where("? IS NULL", bun.Ident(ColumnValidTo)).
+		Where("deleted_at IS NULL").
+		Returning("*")
+	if userId != nil {
+		query.Where("userId = ?", userId)
+	}
+
+	_, err := query.Exec(ctx, current)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.NewNotFoundError("NOT_FOUND", fmt.Errorf("Entity with id %s could not be found.", id))
+		}
+		logger.For(ctx, logComponent).Error().
+			Err(err).
+			Str("id", id.String()).
+			Str("model", fmt.Sprintf("%T", *current)).
+			Msg("[BASE REPOSITORY] - appendVersion - Error closing current version")
+		return errors.NewUnkownDatabaseError(err)
+	}
+
+	_, err = db.NewInsert().
+		Model(current).
+		Value(ColumnValidFrom, "?", now).
+		Value(ColumnValidTo, "NULL").
+		Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().
+			Err(err).
+			Str("id", id.String()).
+			Str("model", fmt.Sprintf("%T", *current)).
+			Msg("[BASE REPOSITORY] - appendVersion - Error inserting new version")
+		return errors.NewUnkownDatabaseError(err)
+	}
+	return nil
+}
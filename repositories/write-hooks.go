@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/logger"
+	"github.com/uptrace/bun"
+)
+
+type ChangeAction string
+
+const (
+	ChangeActionCreate ChangeAction = "CREATE"
+	ChangeActionUpdate ChangeAction = "UPDATE"
+	ChangeActionDelete ChangeAction = "DELETE"
+)
+
+// Change describes a write done through a PostgresRepository. Before is nil
+// on creation and After is the soft deleted entity on deletion.
+type Change struct {
+	Action     ChangeAction
+	EntityType string
+	EntityId   string
+	Before     interface{}
+	After      interface{}
+}
+
+// WriteHook is called after every repository write, inside the same
+// transaction. Returning an error rolls the write back.
+type WriteHook interface {
+	AfterWrite(ctx *gin.Context, db bun.IDB, change Change) error
+}
+
+func (r *PostgresRepository[M]) AddWriteHook(hook WriteHook) *PostgresRepository[M] {
+	r.writeHooks = append(r.writeHooks, hook)
+	return r
+}
+
+func (r *PostgresRepository[M]) hasWriteHooks() bool {
+	return len(r.writeHooks) > 0
+}
+
+// write runs a repository write. When hooks are registered or the repository
+// is versioned, the write and the hooks share a transaction, the one already
+// in the context if there's one.
+func (r *PostgresRepository[M]) write(ctx *gin.Context, fn func(db bun.IDB) (Change, error)) error {
+	if !r.hasWriteHooks() && !r.versioned {
+		_, err := fn(r.client.GetDB(ctx))
+		return err
+	}
+
+	return r.client.runInTx(ctx, func(db bun.IDB) error {
+		change, err := fn(db)
+		if err != nil {
+			return err
+		}
+
+		for _, hook := range r.writeHooks {
+			if err := hook.AfterWrite(ctx, db, change); err != nil {
+				logger.For(ctx, logComponent).Error().
+					Err(err).
+					Str("action", string(change.Action)).
+					Str("entityType", change.EntityType).
+					Str("hook", fmt.Sprintf("%T", hook)).
+					Msg("[BASE REPOSITORY] - write - Error in write hook")
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *PostgresRepository[M]) newChange(action ChangeAction, before *M, after *M) Change {
+	change := Change{
+		Action:     action,
+		EntityType: r.table().Name,
+	}
+	if before != nil {
+		change.Before = *before
+		change.EntityId = r.entityId(before)
+	}
+	if after != nil {
+		change.After = *after
+		change.EntityId = r.entityId(after)
+	}
+	return change
+}
+
+func (r *PostgresRepository[M]) entityId(entity *M) string {
+	pks := r.table().PKs
+	if len(pks) == 0 {
+		return ""
+	}
+	return fmt.Sprint(pks[0].Value(reflect.ValueOf(entity).Elem()).Interface())
+}
+
+func (client *BunPostgresDatabaseClient) runInTx(ctx context.Context, fn func(db bun.IDB) error) error {
+	if tx := client.getTx(ctx); tx != nil {
+		return fn(*tx)
+	}
+
+	return client.DB.RunInTx(ctx, nil, func(_ context.Context, tx bun.Tx) error {
+		return fn(tx)
+	})
+}
@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/config"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/metrics"
+	"github.com/ginerator/base/middlewares"
+	postgres "github.com/ginerator/base/repositories"
+)
+
+const defaultSlowQueriesLimit = 20
+
+// AttachDatabaseRoutes serves the slow queries of the client at
+// /sys/db/slow-queries, to users with the configured permission, and adds its
+// pool and migration metrics to /sys/metrics. Sys routes aren't
+// authenticated, so the authentication middleware is passed in.
+func AttachDatabaseRoutes(sys *gin.RouterGroup, debugConfig *config.DebugConfig, client *postgres.BunPostgresDatabaseClient, authenticate gin.HandlerFunc) {
+	if err := metrics.RegisterDatabase(client); err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[SYS ROUTES] - AttachDatabaseRoutes - Error registering database metrics")
+	}
+
+	permissions := middlewares.AuthorizationPermissions{Admin: debugConfig.DbPermission}
+	group := middlewares.AuthorizedGroup(sys.Group("", authenticate), "/db", permissions)
+	group.GET("/slow-queries", func(ctx *gin.Context) {
+		limit, err := strconv.Atoi(ctx.Query("limit"))
+		if err != nil || limit <= 0 {
+			limit = defaultSlowQueriesLimit
+		}
+		ctx.JSON(http.StatusOK, gin.H{"data": client.SlowQueries(limit)})
+	})
+}
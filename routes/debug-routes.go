@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"net/http/pprof"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/config"
+	"github.com/ginerator/base/middlewares"
+)
+
+// AttachDebugRoutes serves the pprof profiles at /sys/debug/pprof when
+// enabled, to users with the configured permission. Sys routes aren't
+// authenticated, so the authentication middleware is passed in.
+func AttachDebugRoutes(sys *gin.RouterGroup, debugConfig *config.DebugConfig, authenticate gin.HandlerFunc) {
+	if enabled, _ := strconv.ParseBool(debugConfig.PprofEnabled); !enabled {
+		return
+	}
+
+	permissions := middlewares.AuthorizationPermissions{Admin: debugConfig.PprofPermission}
+	group := middlewares.AuthorizedGroup(sys.Group("/debug", authenticate), "/pprof", permissions)
+	group.GET("/", gin.WrapF(pprof.Index))
+	group.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	group.GET("/profile", gin.WrapF(pprof.Profile))
+	group.GET("/symbol", gin.WrapF(pprof.Symbol))
+	group.POST("/symbol", gin.WrapF(pprof.Symbol))
+	group.GET("/trace", gin.WrapF(pprof.Trace))
+	// Heap, goroutine, allocs, block, mutex and threadcreate
+	group.GET("/:profile", func(ctx *gin.Context) {
+		pprof.Handler(ctx.Param("profile")).ServeHTTP(ctx.Writer, ctx.Request)
+	})
+}
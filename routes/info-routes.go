@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/config"
+	"github.com/ginerator/base/middlewares"
+	postgres "github.com/ginerator/base/repositories"
+	"github.com/ginerator/base/utils"
+)
+
+type routeInfo struct {
+	Method      string      `json:"method"`
+	Path        string      `json:"path"`
+	Permissions interface{} `json:"permissions,omitempty"`
+}
+
+type migrationInfo struct {
+	Version uint   `json:"version"`
+	Dirty   bool   `json:"dirty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AttachInfoRoutes serves the build, uptime, migration and routes of the app
+// at /sys/info, to users with the configured permission. Sys routes aren't
+// authenticated, so the authentication middleware is passed in. Only the
+// permissions of groups built with AuthorizedGroup are listed with the routes,
+// routes checking them with CheckAuthorization have to be registered through
+// RegisterRoutePermissions to show them.
+func AttachInfoRoutes(sys *gin.RouterGroup, router *gin.Engine, appConfig *config.AppConfig, debugConfig *config.DebugConfig, client *postgres.BunPostgresDatabaseClient, authenticate gin.HandlerFunc) {
+	permissions := middlewares.AuthorizationPermissions{Admin: debugConfig.InfoPermission}
+	group := middlewares.AuthorizedGroup(sys.Group("", authenticate), "/info", permissions)
+	group.GET("", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{
+			"name":      appConfig.Name,
+			"env":       appConfig.Env,
+			"build":     utils.GetBuildInfo(),
+			"uptime":    utils.Uptime().Round(time.Second).String(),
+			"migration": migrationStatus(client),
+			"routes":    listRoutes(router),
+		})
+	})
+}
+
+func migrationStatus(client *postgres.BunPostgresDatabaseClient) migrationInfo {
+	migration := migrationInfo{}
+	version, dirty, err := client.CachedMigrationVersion()
+	if err != nil {
+		migration.Error = err.Error()
+	}
+	migration.Version, migration.Dirty = version, dirty
+	return migration
+}
+
+func listRoutes(router *gin.Engine) []routeInfo {
+	routes := make([]routeInfo, 0)
+	for _, route := range router.Routes() {
+		info := routeInfo{Method: route.Method, Path: route.Path}
+		if permissions, exists := middlewares.RoutePermissions(route.Path); exists {
+			info.Permissions = permissions
+		}
+		routes = append(routes, info)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
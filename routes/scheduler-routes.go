@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/scheduler"
+)
+
+func AttachSchedulerRoutes(sys *gin.RouterGroup, taskScheduler *scheduler.Scheduler) {
+	sys.GET("/scheduler", func(ctx *gin.Context) {
+		statuses, err := taskScheduler.Status(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"data": statuses})
+	})
+}
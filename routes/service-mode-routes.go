@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/middlewares"
+	"github.com/ginerator/base/model/servicemode"
+	postgres "github.com/ginerator/base/repositories"
+	"github.com/go-playground/validator/v10"
+)
+
+// AttachServiceModeRoutes serves the service mode at /sys/mode to users with
+// the admin permission, who switch it with a PATCH. Sys routes aren't
+// authenticated, so the authentication middleware is passed in.
+func AttachServiceModeRoutes(sys *gin.RouterGroup, store *postgres.ServiceModeStore, validate *validator.Validate, authenticate gin.HandlerFunc, adminPermission interface{}) {
+	permissions := middlewares.AuthorizationPermissions{Admin: adminPermission}
+	group := middlewares.AuthorizedGroup(sys.Group("", authenticate), "/mode", permissions)
+	group.GET("", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"data": store.Mode()})
+	})
+	group.PATCH("", func(ctx *gin.Context) {
+		var request servicemode.UpdateModeRequest
+		decoder := json.NewDecoder(ctx.Request.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&request); err != nil {
+			ctx.Error(errors.NewInvalidPayloadError("INVALID_PAYLOAD", err))
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			ctx.Error(errors.NewInvalidPayloadError("INVALID_PAYLOAD", err))
+			return
+		}
+
+		mode, err := store.Update(ctx, request)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"data": mode})
+	})
+}
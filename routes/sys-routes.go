@@ -4,25 +4,61 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/metrics"
 	"github.com/ginerator/base/utils"
 )
 
+const logComponent = "routes"
+
 func AttachSysRoutes(router *gin.Engine, appName string, appStateManager *utils.AppStateManager) *gin.RouterGroup {
 	sys := router.Group("/sys")
-	sys.GET("/health", func(ctx *gin.Context) {
-		_, err := appStateManager.DependenciesConnected()
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{
-				"name":   appName,
-				"status": "DOWN",
-				"error":  err.Error(),
-			})
+	// The process answers, dependencies are left out so a database blip
+	// doesn't get healthy pods restarted
+	sys.GET("/live", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"name": appName, "status": utils.HealthStatusUp})
+	})
+	// Up once the service calls MarkStarted, so slow migrations don't fail the liveness probe
+	sys.GET("/startup", func(ctx *gin.Context) {
+		if !appStateManager.Started() {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"name": appName, "status": utils.HealthStatusDown})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"name": appName, "status": utils.HealthStatusUp})
+	})
+	// Only draining or a critical dependency being down takes the pod out of rotation
+	sys.GET("/ready", func(ctx *gin.Context) {
+		if appStateManager.Draining() {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"name": appName, "status": utils.HealthStatusDown, "draining": true})
 			return
 		}
-		ctx.JSON(http.StatusOK, gin.H{
-			"name":   appName,
-			"status": "UP",
+		report := appStateManager.CheckDependencies()
+		status := http.StatusOK
+		if report.Status == utils.HealthStatusDown {
+			status = http.StatusServiceUnavailable
+		}
+		ctx.JSON(status, gin.H{
+			"name":         appName,
+			"status":       report.Status,
+			"dependencies": report.Dependencies,
+		})
+	})
+	sys.GET("/health", func(ctx *gin.Context) {
+		report := appStateManager.CheckDependencies()
+		status := http.StatusOK
+		if report.Status == utils.HealthStatusDown {
+			status = http.StatusServiceUnavailable
+		}
+		ctx.JSON(status, gin.H{
+			"name":         appName,
+			"status":       report.Status,
+			"dependencies": report.Dependencies,
+			"details":      appStateManager.DependenciesReport(),
 		})
 	})
+	if err := metrics.RegisterHealth(appStateManager); err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[SYS ROUTES] - AttachSysRoutes - Error registering health metrics")
+	}
+	sys.GET("/metrics", gin.WrapH(metrics.Handler()))
 	return sys
 }
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	controller "github.com/ginerator/base/controllers"
+	"github.com/ginerator/base/middlewares"
+	webhookdispatcher "github.com/ginerator/base/webhooks"
+	"github.com/go-playground/validator/v10"
+)
+
+func AttachWebhookRoutes(router *gin.RouterGroup, dispatcher *webhookdispatcher.Dispatcher, validate *validator.Validate, permissions middlewares.AuthorizationPermissions) *gin.RouterGroup {
+	group := middlewares.AuthorizedGroup(router, "/webhooks", permissions)
+	group.POST("", func(ctx *gin.Context) {
+		controller.Create(ctx, validate, dispatcher.CreateSubscription)
+	})
+	group.GET("", func(ctx *gin.Context) {
+		controller.GetMany(ctx, validate, dispatcher.GetSubscriptions)
+	})
+	group.GET("/:id", func(ctx *gin.Context) {
+		controller.GetOne(ctx, dispatcher.GetSubscription)
+	})
+	group.PATCH("/:id", func(ctx *gin.Context) {
+		controller.UpdateOne(ctx, validate, dispatcher.UpdateSubscription)
+	})
+	group.DELETE("/:id", func(ctx *gin.Context) {
+		controller.DeleteOne(ctx, dispatcher.DeleteSubscription)
+	})
+	group.GET("/:id/deliveries", func(ctx *gin.Context) {
+		controller.GetManyWithExternalId(ctx, validate, dispatcher.GetDeliveries)
+	})
+	return group
+}
@@ -0,0 +1,298 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ginerator/base/logger"
+	postgres "github.com/ginerator/base/repositories"
+	"github.com/robfig/cron/v3"
+	"github.com/uptrace/bun"
+)
+
+const logComponent = "scheduler"
+
+type Options struct {
+	// TaskTimeout bounds a single run of a handler
+	TaskTimeout time.Duration
+	// RecentFailures is how many failures are kept per task
+	RecentFailures int
+}
+
+func DefaultOptions() Options {
+	return Options{
+		TaskTimeout:    10 * time.Minute,
+		RecentFailures: 10,
+	}
+}
+
+type Handler func(ctx context.Context) error
+
+type task struct {
+	name       string
+	expression string
+	schedule   cron.Schedule
+	handler    Handler
+}
+
+// Scheduler runs handlers on cron expressions. Every replica ticks, but a
+// Postgres advisory lock and the next run stored in scheduler_tasks make
+// sure only one of them runs each tick.
+type Scheduler struct {
+	client  *postgres.BunPostgresDatabaseClient
+	options Options
+	runner  string
+
+	tasks map[string]*task
+	mutex sync.RWMutex
+
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+func NewScheduler(client *postgres.BunPostgresDatabaseClient, options Options) *Scheduler {
+	defaults := DefaultOptions()
+	if options.TaskTimeout <= 0 {
+		options.TaskTimeout = defaults.TaskTimeout
+	}
+	if options.RecentFailures <= 0 {
+		options.RecentFailures = defaults.RecentFailures
+	}
+
+	runner, _ := os.Hostname()
+
+	logger.Component(logComponent).Info().Msg("Scheduler initialized.")
+	return &Scheduler{
+		client:  client,
+		options: options,
+		runner:  runner,
+		tasks:   make(map[string]*task),
+	}
+}
+
+// Register adds a task with a standard 5 field cron expression, e.g.
+// "0 3 * * *", or a descriptor such as "@hourly" or "@every 10m".
+func (scheduler *Scheduler) Register(name string, expression string, handler Handler) error {
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return fmt.Errorf("Invalid cron expression '%s' for task %s: %w", expression, name, err)
+	}
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	scheduler.tasks[name] = &task{
+		name:       name,
+		expression: expression,
+		schedule:   schedule,
+		handler:    handler,
+	}
+	return nil
+}
+
+// Start runs the registered tasks until Close is called.
+func (scheduler *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler.cancel = cancel
+
+	scheduler.mutex.RLock()
+	defer scheduler.mutex.RUnlock()
+	for _, task := range scheduler.tasks {
+		scheduler.done.Add(1)
+		go scheduler.loop(ctx, task)
+	}
+	logger.Component(logComponent).Info().Int("tasks", len(scheduler.tasks)).Msg("Scheduler started.")
+}
+
+// Close stops ticking, cancels the context of the running tasks and waits
+// for them to return.
+func (scheduler *Scheduler) Close() {
+	if scheduler.cancel == nil {
+		return
+	}
+	scheduler.cancel()
+	scheduler.done.Wait()
+	logger.Component(logComponent).Info().Msg("Scheduler stopped.")
+}
+
+func (scheduler *Scheduler) loop(ctx context.Context, task *task) {
+	defer scheduler.done.Done()
+
+	tick := task.schedule.Next(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(tick)):
+		}
+
+		next, err := scheduler.runTick(ctx, task)
+		if err != nil && ctx.Err() == nil {
+			logger.Component(logComponent).Error().Err(err).Str("task", task.name).Msg("[SCHEDULER] - loop - Error running tick")
+		}
+		// Replicas wake up when the shared next run is due, so "@every"
+		// schedules don't drift apart on each replica
+		tick = task.schedule.Next(time.Now())
+		if next != nil && next.After(time.Now()) {
+			tick = *next
+		}
+	}
+}
+
+// runTick runs the task if its shared next run is due and no replica is
+// running it. The claim is committed before the handler runs, so no
+// transaction stays open during the run. It returns the next run.
+func (scheduler *Scheduler) runTick(ctx context.Context, task *task) (*time.Time, error) {
+	state, claimed, err := scheduler.claim(ctx, task)
+	if err != nil || !claimed {
+		return state.NextRunAt, err
+	}
+
+	startedAt := time.Now()
+	runErr := scheduler.runHandler(ctx, task)
+	finishedAt := time.Now()
+
+	state.LastDurationMs = finishedAt.Sub(startedAt).Milliseconds()
+	state.LockedUntil = nil
+	state.UpdatedAt = finishedAt
+	if runErr == nil {
+		state.LastSuccessAt = &finishedAt
+		state.Failures = 0
+	} else {
+		logger.Component(logComponent).Error().Err(runErr).Str("task", task.name).Msg("[SCHEDULER] - runTick - Task failed")
+		state.Failures++
+		state.RecentFailures = append([]TaskFailure{{At: finishedAt, Error: runErr.Error()}}, state.RecentFailures...)
+		if len(state.RecentFailures) > scheduler.options.RecentFailures {
+			state.RecentFailures = state.RecentFailures[:scheduler.options.RecentFailures]
+		}
+	}
+
+	// The scheduler may be closing, the result is saved anyway
+	_, err = scheduler.client.DB.NewUpdate().
+		Model(&state).
+		Column("last_duration_ms", "last_success_at", "failures", "recent_failures", "locked_until", "updated_at").
+		WherePK().
+		Exec(context.Background())
+	return state.NextRunAt, err
+}
+
+// claim takes the due run of a task under an advisory lock, moving its
+// next run forward. A task seen for the first time is scheduled, not run.
+func (scheduler *Scheduler) claim(ctx context.Context, task *task) (TaskState, bool, error) {
+	state := TaskState{Name: task.name, Expression: task.expression}
+	claimed := false
+	err := scheduler.client.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		acquired := false
+		err := tx.NewRaw("SELECT pg_try_advisory_xact_lock(?)", postgres.AdvisoryLockKey("scheduler:"+task.name)).Scan(ctx, &acquired)
+		if err != nil || !acquired {
+			return err
+		}
+
+		now := time.Now()
+		next := task.schedule.Next(now)
+		_, err = tx.NewInsert().
+			Model(&state).
+			Value("next_run_at", "?", next).
+			On("CONFLICT (name) DO UPDATE").
+			Set("expression = EXCLUDED.expression").
+			Set("next_run_at = COALESCE(?TableAlias.next_run_at, EXCLUDED.next_run_at)").
+			Returning("*").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if now.Before(*state.NextRunAt) || (state.LockedUntil != nil && now.Before(*state.LockedUntil)) {
+			return nil
+		}
+
+		lockedUntil := now.Add(scheduler.options.TaskTimeout)
+		state.LastTick = state.NextRunAt
+		state.LastRunAt = &now
+		state.LastRunner = &scheduler.runner
+		state.NextRunAt = &next
+		state.LockedUntil = &lockedUntil
+		state.UpdatedAt = now
+		_, err = tx.NewUpdate().
+			Model(&state).
+			Column("last_tick", "last_run_at", "last_runner", "next_run_at", "locked_until", "updated_at").
+			WherePK().
+			Exec(ctx)
+		claimed = err == nil
+		return err
+	})
+	return state, claimed && err == nil, err
+}
+
+// runHandler runs the task with a context cancelled by Close or the timeout.
+func (scheduler *Scheduler) runHandler(ctx context.Context, task *task) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, scheduler.options.TaskTimeout)
+	defer cancel()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("Task panicked: %v", recovered)
+		}
+	}()
+	return task.handler(ctx)
+}
+
+type TaskStatus struct {
+	TaskState
+	// NextTick is when this replica will tick next
+	NextTick time.Time `json:"nextTick"`
+}
+
+// Status returns the registered tasks with their shared run history.
+func (scheduler *Scheduler) Status(ctx context.Context) ([]TaskStatus, error) {
+	scheduler.mutex.RLock()
+	tasks := make([]*task, 0, len(scheduler.tasks))
+	names := make([]string, 0, len(scheduler.tasks))
+	for _, task := range scheduler.tasks {
+		tasks = append(tasks, task)
+		names = append(names, task.name)
+	}
+	scheduler.mutex.RUnlock()
+
+	states := make([]TaskState, 0)
+	if len(names) > 0 {
+		err := scheduler.client.DB.NewSelect().Model(&states).Where("name IN (?)", bun.In(names)).Scan(ctx)
+		if err != nil {
+			logger.Component(logComponent).Error().Err(err).Msg("[SCHEDULER] - Status - Error reading task states")
+			return nil, err
+		}
+	}
+	statesByName := make(map[string]TaskState, len(states))
+	for _, state := range states {
+		statesByName[state.Name] = state
+	}
+
+	statuses := make([]TaskStatus, 0, len(tasks))
+	for _, task := range tasks {
+		state, exists := statesByName[task.name]
+		if !exists {
+			state = TaskState{Name: task.name, Expression: task.expression}
+		}
+		if state.RecentFailures == nil {
+			state.RecentFailures = []TaskFailure{}
+		}
+		statuses = append(statuses, TaskStatus{
+			TaskState: state,
+			NextTick:  task.schedule.Next(time.Now()),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses, nil
+}
+
+// CreateTasksTable creates the scheduler_tasks table if it doesn't exist yet.
+func (scheduler *Scheduler) CreateTasksTable(ctx context.Context) error {
+	_, err := scheduler.client.DB.NewCreateTable().Model((*TaskState)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[SCHEDULER] - CreateTasksTable - Error creating table")
+	}
+	return err
+}
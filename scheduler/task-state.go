@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// TaskState is the run history of a task, shared by every replica.
+type TaskState struct {
+	bun.BaseModel `bun:"table:scheduler_tasks"`
+
+	Name           string     `bun:"name,pk" json:"name"`
+	Expression     string     `bun:"expression,notnull" json:"expression"`
+	LastTick       *time.Time `bun:"last_tick" json:"lastTick,omitempty"`
+	LastRunAt      *time.Time `bun:"last_run_at" json:"lastRunAt,omitempty"`
+	LastSuccessAt  *time.Time `bun:"last_success_at" json:"lastSuccessAt,omitempty"`
+	LastDurationMs int64      `bun:"last_duration_ms,notnull,default:0" json:"lastDurationMs"`
+	LastRunner     *string    `bun:"last_runner" json:"lastRunner,omitempty"`
+	NextRunAt      *time.Time `bun:"next_run_at" json:"nextRunAt,omitempty"`
+	// LockedUntil is set while a replica runs the task, so a run outlasting
+	// the schedule doesn't overlap with the next one
+	LockedUntil    *time.Time    `bun:"locked_until" json:"lockedUntil,omitempty"`
+	Failures       int           `bun:"failures,notnull,default:0" json:"consecutiveFailures"`
+	RecentFailures []TaskFailure `bun:"recent_failures,type:jsonb" json:"recentFailures"`
+	UpdatedAt      time.Time     `bun:"updated_at,notnull,default:current_timestamp" json:"updatedAt"`
+}
+
+type TaskFailure struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error"`
+}
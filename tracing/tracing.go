@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ginerator/base/config"
+	"github.com/ginerator/base/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+
+	logComponent    = "tracing"
+	shutdownTimeout = 5 * time.Second
+)
+
+// Provider exports the spans of the service. It's registered as the global
+// tracer provider, used by the tracing middleware and bunotel.
+type Provider struct {
+	provider *sdktrace.TracerProvider
+}
+
+// NewProvider sets up the exporter from the configuration and registers the
+// W3C trace context propagator. With the `none` exporter spans are still
+// created, so ids propagate, but not exported.
+func NewProvider(tracingConfig *config.TracingConfig, serviceName string) (*Provider, error) {
+	sampleRatio, err := strconv.ParseFloat(tracingConfig.SampleRatio, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid tracing sample ratio '%s': %w", tracingConfig.SampleRatio, err)
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	}
+
+	switch tracingConfig.Exporter {
+	case ExporterNone, "":
+	case ExporterOTLP:
+		exporterOptions := []otlptracehttp.Option{otlptracehttp.WithEndpoint(tracingConfig.Endpoint)}
+		if insecure, _ := strconv.ParseBool(tracingConfig.Insecure); insecure {
+			exporterOptions = append(exporterOptions, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), exporterOptions...)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithSyncer(exporter))
+	default:
+		return nil, fmt.Errorf("Unknown tracing exporter '%s', expected %s, %s or %s", tracingConfig.Exporter, ExporterNone, ExporterOTLP, ExporterStdout)
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	logger.Component(logComponent).Info().Str("exporter", tracingConfig.Exporter).Msg("Tracing initialized.")
+	return &Provider{provider: provider}, nil
+}
+
+// Close flushes the spans not exported yet.
+func (provider *Provider) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := provider.provider.Shutdown(ctx); err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[TRACING] - Close - Error flushing spans")
+	}
+}
@@ -1,9 +1,15 @@
 package utils
 
 import (
-	"github.com/rs/zerolog/log"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ginerator/base/logger"
 )
 
+const logComponent = "utils"
+
 type Closable interface {
 	Close()
 }
@@ -12,45 +18,145 @@ type Monitorable interface {
 	IsConnected() (bool, error)
 }
 
+// Reportable dependencies add details, e.g. queue depths, to the health report.
+type Reportable interface {
+	Report() (interface{}, error)
+}
+
+type namedClosable struct {
+	name     string
+	closable Closable
+}
+
 type AppStateManager struct {
-	closableDependencies    map[string]Closable
-	monitorableDependencies map[string]Monitorable
+	// Closables are kept in registration order, they're closed in reverse
+	workers                 []namedClosable
+	closableDependencies    []namedClosable
+	monitorableDependencies map[string]monitoredDependency
+	reportableDependencies  map[string]Reportable
+	mutex                   sync.RWMutex
+
+	lifecycle LifecycleOptions
+	health    healthCache
+	started   atomic.Bool
+	draining  atomic.Bool
 }
 
 func NewAppStateManager() *AppStateManager {
 	return &AppStateManager{
-		closableDependencies:    make(map[string]Closable),
-		monitorableDependencies: make(map[string]Monitorable),
+		monitorableDependencies: make(map[string]monitoredDependency),
+		reportableDependencies:  make(map[string]Reportable),
+		lifecycle:               DefaultLifecycleOptions(),
 	}
 }
 
+// AddWorker adds a background worker, e.g. a job queue or a scheduler. Workers
+// are stopped before the dependencies they may use are closed.
+func (manager *AppStateManager) AddWorker(name string, worker Closable) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.workers = append(manager.workers, namedClosable{name: name, closable: worker})
+}
+
 func (manager *AppStateManager) AddClosableDependency(name string, dependency Closable) {
-	manager.closableDependencies[name] = dependency
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.closableDependencies = append(manager.closableDependencies, namedClosable{name: name, closable: dependency})
+}
+
+// AddMonitorableDependency adds a dependency to the health checks. It's
+// critical, with a timeout of 2 seconds, unless options say otherwise.
+func (manager *AppStateManager) AddMonitorableDependency(name string, dependency Monitorable, opts ...CheckOption) {
+	options := checkOptions{critical: true, timeout: defaultCheckTimeout}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.monitorableDependencies[name] = monitoredDependency{dependency: dependency, options: options}
 }
 
-func (manager *AppStateManager) AddMonitorableDependency(name string, dependency Monitorable) {
-	manager.monitorableDependencies[name] = dependency
+func (manager *AppStateManager) AddReportableDependency(name string, dependency Reportable) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.reportableDependencies[name] = dependency
 }
 
+// Shutdown stops the workers, then closes the dependencies, both in reverse
+// registration order and each stage within its timeout.
 func (manager *AppStateManager) Shutdown() {
-	log.Info().Msg("Closing dependencies...")
-	for _, dependency := range manager.closableDependencies {
-		dependency.Close()
-	}
-	log.Info().Msg("All dependencies closed")
+	manager.mutex.RLock()
+	workers := append([]namedClosable(nil), manager.workers...)
+	dependencies := append([]namedClosable(nil), manager.closableDependencies...)
+	lifecycle := manager.lifecycle
+	manager.mutex.RUnlock()
+
+	logger.Component(logComponent).Info().Msg("Stopping workers...")
+	closeInReverse(workers, lifecycle.WorkersTimeout)
+	logger.Component(logComponent).Info().Msg("Closing dependencies...")
+	closeInReverse(dependencies, lifecycle.CloseTimeout)
+	logger.Component(logComponent).Info().Msg("All dependencies closed")
 }
 
+// DependenciesConnected returns whether every critical dependency is
+// connected, with the error of one that isn't.
 func (manager *AppStateManager) DependenciesConnected() (bool, error) {
-	for _, dependency := range manager.monitorableDependencies {
-		isConnected, err := dependency.IsConnected()
-		if err != nil {
-			return false, err
+	report := manager.CheckDependencies()
+	if report.Status != HealthStatusDown {
+		return true, nil
+	}
+	for name, health := range report.Dependencies {
+		if health.Critical && health.Status == HealthStatusDown {
+			return false, fmt.Errorf("Dependency %s is down: %s", name, health.Error)
 		}
+	}
+	return false, nil
+}
 
-		if !isConnected {
-			return false, nil
-		}
+// MarkStarted tells the startup probe the service finished starting, e.g.
+// once migrations ran and workers started. Run calls it once it listens.
+func (manager *AppStateManager) MarkStarted() {
+	manager.started.Store(true)
+}
+
+func (manager *AppStateManager) Started() bool {
+	return manager.started.Load()
+}
+
+// Draining is true once the service is shutting down, so the readiness
+// probe takes it out of rotation before it stops accepting requests.
+func (manager *AppStateManager) Draining() bool {
+	return manager.draining.Load()
+}
+
+// DependencyStatuses returns whether each monitorable dependency is connected.
+func (manager *AppStateManager) DependencyStatuses() map[string]bool {
+	report := manager.CheckDependencies()
+	statuses := make(map[string]bool, len(report.Dependencies))
+	for name, health := range report.Dependencies {
+		statuses[name] = health.Status == HealthStatusUp
+	}
+	return statuses
+}
+
+func (manager *AppStateManager) DependenciesReport() map[string]interface{} {
+	manager.mutex.RLock()
+	dependencies := make(map[string]Reportable, len(manager.reportableDependencies))
+	for name, dependency := range manager.reportableDependencies {
+		dependencies[name] = dependency
 	}
+	manager.mutex.RUnlock()
 
-	return true, nil
+	reports := make(map[string]interface{}, len(dependencies))
+	for name, dependency := range dependencies {
+		report, err := dependency.Report()
+		if err != nil {
+			logger.Component(logComponent).Error().Err(err).Str("dependency", name).Msg("[APP STATE MANAGER] - DependenciesReport - Error building report")
+			reports[name] = map[string]string{"error": err.Error()}
+			continue
+		}
+		reports[name] = report
+	}
+	return reports
 }
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ExponentialBackoff returns the delay before retry number attempt (starting
+// at 1), doubling from base up to max, with up to 20% of random jitter so
+// retries of many replicas don't line up.
+func ExponentialBackoff(attempt int, base time.Duration, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := float64(base) * math.Pow(2, float64(attempt-1))
+	if delay > float64(max) {
+		delay = float64(max)
+	}
+
+	jitter := delay * 0.2 * rand.Float64()
+	return time.Duration(delay - jitter)
+}
@@ -0,0 +1,35 @@
+//go:build unit
+
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginerator/base/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	base, max := time.Second, time.Minute
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, test := range tests {
+		// Jitter takes up to 20% off the delay, never adds to it
+		for i := 0; i < 100; i++ {
+			backoff := utils.ExponentialBackoff(test.attempt, base, max)
+			assert.LessOrEqual(t, backoff, test.expected, "attempt %d", test.attempt)
+			assert.GreaterOrEqual(t, backoff, test.expected*8/10, "attempt %d", test.attempt)
+		}
+	}
+}
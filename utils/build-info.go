@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// Set at build time, e.g. -ldflags "-X github.com/ginerator/base/utils.Version=1.4.0".
+// When not set, they're read from the module and VCS info Go embeds in the binary.
+var (
+	Version   = ""
+	Commit    = ""
+	BuildTime = ""
+)
+
+var processStartedAt = time.Now()
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	BuildTime string `json:"buildTime,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{Version: Version, Commit: Commit, BuildTime: BuildTime}
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = buildInfo.GoVersion
+	if info.Version == "" {
+		info.Version = buildInfo.Main.Version
+	}
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// Uptime returns how long the process has been running.
+func Uptime() time.Duration {
+	return time.Since(processStartedAt)
+}
@@ -25,13 +25,16 @@ var queryControlParams = func() []string {
 	return params
 }()
 
+// Params read by the repository instead of being used as filters
+var queryReadParams = []string{AsOfTag}
+
 func filterOutDeletedEntities(dbQuery *bun.SelectQuery) {
 	dbQuery.Where("deleted_at IS NULL")
 }
 
 func urlToDbQuery(gCtx *gin.Context, dbQuery *bun.SelectQuery) {
 	for param, values := range gCtx.Request.URL.Query() {
-		if !lo.Contains(queryControlParams, param) {
+		if !lo.Contains(queryControlParams, param) && !lo.Contains(queryReadParams, param) {
 			if len(values) > 1 {
 				dbQuery.Where(fmt.Sprintf("%s IN (?)", param), bun.In(values))
 			} else if len(values) == 1 {
@@ -77,3 +80,9 @@ func BuildQuery(gCtx *gin.Context, dbQuery *bun.SelectQuery) (int, int) {
 	filterOutDeletedEntities(dbQuery)
 	return setQueryControlParams(gCtx, dbQuery)
 }
+
+// BuildControlQuery only applies sorting and pagination, for queries over
+// tables that aren't filtered by the request.
+func BuildControlQuery(gCtx *gin.Context, dbQuery *bun.SelectQuery) (int, int) {
+	return setQueryControlParams(gCtx, dbQuery)
+}
@@ -0,0 +1,20 @@
+package utils
+
+import "github.com/rs/zerolog"
+
+// CorrelationHook adds the request id and trace id to log lines given the
+// context of a request with `.Ctx(ctx)`.
+type CorrelationHook struct{}
+
+func (hook CorrelationHook) Run(event *zerolog.Event, _ zerolog.Level, _ string) {
+	ctx := event.GetCtx()
+	if ctx == nil {
+		return
+	}
+	if requestId := GetRequestId(ctx); requestId != "" {
+		event.Str(RequestIdTag, requestId)
+	}
+	if traceparent := GetTraceparent(ctx); traceparent != nil {
+		event.Str("traceId", traceparent.TraceId)
+	}
+}
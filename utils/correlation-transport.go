@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIdHeader = "X-Request-ID"
+const TraceparentHeader = "traceparent"
+
+// CorrelationTransport forwards the request id and trace context found in
+// the context of outbound requests, so calls can be followed across services.
+type CorrelationTransport struct {
+	Base http.RoundTripper
+}
+
+func NewCorrelationTransport(base http.RoundTripper) *CorrelationTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &CorrelationTransport{Base: base}
+}
+
+func (transport *CorrelationTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+	requestId := GetRequestId(ctx)
+	traceparent := GetTraceparent(ctx)
+	if requestId == "" && traceparent == nil && !trace.SpanContextFromContext(ctx).IsValid() {
+		return transport.Base.RoundTrip(request)
+	}
+
+	// RoundTrippers mustn't modify the request they're given
+	request = request.Clone(ctx)
+	if requestId != "" && request.Header.Get(RequestIdHeader) == "" {
+		request.Header.Set(RequestIdHeader, requestId)
+	}
+	if request.Header.Get(TraceparentHeader) == "" {
+		if trace.SpanContextFromContext(ctx).IsValid() {
+			otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+		} else if traceparent != nil {
+			request.Header.Set(TraceparentHeader, traceparent.Child().String())
+		}
+	}
+	return transport.Base.RoundTrip(request)
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const AsOfTag = "asOf"
+
+func GetAsOf(ctx *gin.Context) *time.Time {
+	if asOf, exists := ctx.Keys[AsOfTag]; exists {
+		if timeAsOf, ok := asOf.(time.Time); ok {
+			return &timeAsOf
+		}
+	}
+	return nil
+}
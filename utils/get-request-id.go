@@ -0,0 +1,25 @@
+package utils
+
+import "context"
+
+const (
+	RequestIdTag   = "requestId"
+	TraceparentTag = "traceparent"
+)
+
+// GetRequestId returns the id of the request being served, from a gin
+// context or the context of its request. Empty outside of requests.
+func GetRequestId(ctx context.Context) string {
+	if requestId, ok := ctx.Value(RequestIdTag).(string); ok {
+		return requestId
+	}
+	return ""
+}
+
+// GetTraceparent returns the W3C trace context of the request being served.
+func GetTraceparent(ctx context.Context) *Traceparent {
+	if traceparent, ok := ctx.Value(TraceparentTag).(Traceparent); ok {
+		return &traceparent
+	}
+	return nil
+}
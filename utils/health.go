@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type HealthStatus string
+
+const (
+	HealthStatusUp       HealthStatus = "UP"
+	HealthStatusDegraded HealthStatus = "DEGRADED"
+	HealthStatusDown     HealthStatus = "DOWN"
+
+	defaultCheckTimeout = 2 * time.Second
+	// healthCacheTTL keeps probes from several kubelets and load balancers
+	// from pinging every dependency on each request.
+	healthCacheTTL = time.Second
+)
+
+type DependencyHealth struct {
+	Status    HealthStatus `json:"status"`
+	Critical  bool         `json:"critical"`
+	LatencyMs float64      `json:"latencyMs"`
+	Error     string       `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status       HealthStatus                `json:"status"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+	CheckedAt    time.Time                   `json:"checkedAt"`
+}
+
+type checkOptions struct {
+	critical bool
+	timeout  time.Duration
+}
+
+type CheckOption func(*checkOptions)
+
+// NonCritical marks a dependency the service can work without, e.g. a cache.
+// It being down degrades the service instead of taking it out of rotation.
+func NonCritical() CheckOption {
+	return func(options *checkOptions) {
+		options.critical = false
+	}
+}
+
+func WithCheckTimeout(timeout time.Duration) CheckOption {
+	return func(options *checkOptions) {
+		options.timeout = timeout
+	}
+}
+
+type monitoredDependency struct {
+	dependency Monitorable
+	options    checkOptions
+}
+
+type healthCache struct {
+	mutex     sync.Mutex
+	report    *HealthReport
+	checkedAt time.Time
+}
+
+// CheckDependencies checks every monitorable dependency in parallel, each
+// within its timeout. The service is DOWN if a critical dependency is, and
+// DEGRADED if a non critical one is. Reports are cached for a second.
+func (manager *AppStateManager) CheckDependencies() HealthReport {
+	manager.health.mutex.Lock()
+	defer manager.health.mutex.Unlock()
+
+	if manager.health.report != nil && time.Since(manager.health.checkedAt) < healthCacheTTL {
+		return *manager.health.report
+	}
+
+	manager.mutex.RLock()
+	dependencies := make(map[string]monitoredDependency, len(manager.monitorableDependencies))
+	for name, monitored := range manager.monitorableDependencies {
+		dependencies[name] = monitored
+	}
+	manager.mutex.RUnlock()
+
+	report := HealthReport{
+		Status:       HealthStatusUp,
+		Dependencies: make(map[string]DependencyHealth, len(dependencies)),
+		CheckedAt:    time.Now(),
+	}
+	var (
+		wait  sync.WaitGroup
+		mutex sync.Mutex
+	)
+	for name, monitored := range dependencies {
+		wait.Add(1)
+		go func(name string, monitored monitoredDependency) {
+			defer wait.Done()
+			health := checkDependency(monitored)
+			mutex.Lock()
+			report.Dependencies[name] = health
+			mutex.Unlock()
+		}(name, monitored)
+	}
+	wait.Wait()
+
+	for _, health := range report.Dependencies {
+		if health.Status == HealthStatusUp {
+			continue
+		}
+		if health.Critical {
+			report.Status = HealthStatusDown
+			break
+		}
+		report.Status = HealthStatusDegraded
+	}
+
+	manager.health.report = &report
+	manager.health.checkedAt = time.Now()
+	return report
+}
+
+// checkDependency runs a check within its timeout. IsConnected takes no
+// context, so a check that hangs is abandoned rather than cancelled.
+func checkDependency(monitored monitoredDependency) DependencyHealth {
+	type result struct {
+		connected bool
+		err       error
+	}
+	results := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		connected, err := monitored.dependency.IsConnected()
+		results <- result{connected, err}
+	}()
+
+	health := DependencyHealth{Status: HealthStatusDown, Critical: monitored.options.critical}
+	select {
+	case checked := <-results:
+		switch {
+		case checked.err != nil:
+			health.Error = checked.err.Error()
+		case !checked.connected:
+			health.Error = "Not connected."
+		default:
+			health.Status = HealthStatusUp
+		}
+	case <-time.After(monitored.options.timeout):
+		health.Error = fmt.Sprintf("Check timed out after %s.", monitored.options.timeout)
+	}
+	health.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	return health
+}
@@ -0,0 +1,52 @@
+//go:build unit
+
+package utils_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ginerator/base/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type dependency struct {
+	delay time.Duration
+	err   error
+}
+
+func (d dependency) IsConnected() (bool, error) {
+	time.Sleep(d.delay)
+	return d.err == nil, d.err
+}
+
+func TestCheckDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		cache    dependency
+		database dependency
+		expected utils.HealthStatus
+	}{
+		{"up", dependency{}, dependency{}, utils.HealthStatusUp},
+		{"non critical down", dependency{err: errors.New("refused")}, dependency{}, utils.HealthStatusDegraded},
+		{"critical down", dependency{}, dependency{err: errors.New("refused")}, utils.HealthStatusDown},
+		{"critical timed out", dependency{}, dependency{delay: time.Second}, utils.HealthStatusDown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := utils.NewAppStateManager()
+			manager.AddMonitorableDependency("cache", test.cache, utils.NonCritical())
+			manager.AddMonitorableDependency("database", test.database, utils.WithCheckTimeout(50*time.Millisecond))
+
+			start := time.Now()
+			report := manager.CheckDependencies()
+			assert.Less(t, time.Since(start), 500*time.Millisecond)
+			assert.Equal(t, test.expected, report.Status)
+			assert.Len(t, report.Dependencies, 2)
+			assert.False(t, report.Dependencies["cache"].Critical)
+			assert.True(t, report.Dependencies["database"].Critical)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/logger"
+)
+
+type LifecycleOptions struct {
+	// PreStopDelay is how long the service keeps serving once it's draining,
+	// so load balancers notice it isn't ready anymore before it stops listening.
+	// Unlike the timeouts, 0 is kept, e.g. for local development
+	PreStopDelay time.Duration
+	// DrainTimeout bounds waiting for in-flight requests to finish
+	DrainTimeout time.Duration
+	// WorkersTimeout bounds stopping every background worker
+	WorkersTimeout time.Duration
+	// CloseTimeout bounds closing every dependency
+	CloseTimeout time.Duration
+}
+
+// DefaultLifecycleOptions fit in the 30 seconds Kubernetes waits by default
+// before killing a pod.
+func DefaultLifecycleOptions() LifecycleOptions {
+	return LifecycleOptions{
+		PreStopDelay:   5 * time.Second,
+		DrainTimeout:   15 * time.Second,
+		WorkersTimeout: 5 * time.Second,
+		CloseTimeout:   5 * time.Second,
+	}
+}
+
+func (manager *AppStateManager) SetLifecycleOptions(options LifecycleOptions) {
+	defaults := DefaultLifecycleOptions()
+	if options.PreStopDelay < 0 {
+		options.PreStopDelay = defaults.PreStopDelay
+	}
+	if options.DrainTimeout <= 0 {
+		options.DrainTimeout = defaults.DrainTimeout
+	}
+	if options.WorkersTimeout <= 0 {
+		options.WorkersTimeout = defaults.WorkersTimeout
+	}
+	if options.CloseTimeout <= 0 {
+		options.CloseTimeout = defaults.CloseTimeout
+	}
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.lifecycle = options
+}
+
+// Run serves the engine on addr until ctx is done or the process gets
+// SIGINT or SIGTERM, then shuts down gracefully: readiness starts failing,
+// requests keep being served for PreStopDelay, in-flight requests are
+// drained, workers are stopped and dependencies closed. It returns the
+// error the server failed with, if any, including failing to listen on addr,
+// in which case the app is never marked as started.
+func (manager *AppStateManager) Run(ctx context.Context, engine *gin.Engine, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Str("addr", addr).Msg("[APP STATE MANAGER] - Run - Error listening")
+		manager.Shutdown()
+		return err
+	}
+
+	manager.mutex.RLock()
+	lifecycle := manager.lifecycle
+	manager.mutex.RUnlock()
+
+	server := &http.Server{Addr: addr, Handler: engine}
+	serverErrors := make(chan error, 1)
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErrors <- err
+		}
+	}()
+	logger.Component(logComponent).Info().Str("addr", addr).Msg("Server started.")
+	manager.MarkStarted()
+
+	signalCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var serverErr error
+	select {
+	case serverErr = <-serverErrors:
+		logger.Component(logComponent).Error().Err(serverErr).Msg("[APP STATE MANAGER] - Run - Server failed")
+	case <-signalCtx.Done():
+		logger.Component(logComponent).Info().Msg("Shutting down...")
+	}
+	// A second signal kills the process right away
+	stop()
+
+	manager.draining.Store(true)
+	if serverErr == nil {
+		time.Sleep(lifecycle.PreStopDelay)
+
+		drainCtx, cancel := context.WithTimeout(context.Background(), lifecycle.DrainTimeout)
+		defer cancel()
+		if err := server.Shutdown(drainCtx); err != nil {
+			logger.Component(logComponent).Warn().Err(err).Msg("[APP STATE MANAGER] - Run - Requests still in flight, closing connections")
+			server.Close()
+		} else {
+			logger.Component(logComponent).Info().Msg("Server stopped.")
+		}
+	}
+
+	manager.Shutdown()
+	return serverErr
+}
+
+// closeInReverse closes in reverse order within a timeout for all of them.
+// Close takes no context, so the ones left when it's exceeded are abandoned.
+func closeInReverse(closables []namedClosable, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := len(closables) - 1; i >= 0; i-- {
+			logger.Component(logComponent).Info().Str("dependency", closables[i].name).Msg("Closing...")
+			closables[i].closable.Close()
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logger.Component(logComponent).Error().Dur("timeout", timeout).Msg("[APP STATE MANAGER] - closeInReverse - Timed out closing")
+	}
+}
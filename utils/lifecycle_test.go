@@ -0,0 +1,67 @@
+//go:build unit
+
+package utils_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type closable struct {
+	name   string
+	closed *[]string
+}
+
+func (c closable) Close() {
+	*c.closed = append(*c.closed, c.name)
+}
+
+func TestRun(t *testing.T) {
+	closed := []string{}
+	manager := utils.NewAppStateManager()
+	manager.SetLifecycleOptions(utils.LifecycleOptions{PreStopDelay: 0})
+	manager.AddClosableDependency("database", closable{"database", &closed})
+	manager.AddClosableDependency("cache", closable{"cache", &closed})
+	manager.AddWorker("queue", closable{"queue", &closed})
+	manager.AddWorker("scheduler", closable{"scheduler", &closed})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	gin.SetMode(gin.TestMode)
+	result := make(chan error, 1)
+	go func() {
+		result <- manager.Run(ctx, gin.New(), "127.0.0.1:0")
+	}()
+
+	assert.Eventually(t, manager.Started, time.Second, 10*time.Millisecond)
+	cancel()
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return")
+	}
+	assert.True(t, manager.Draining())
+	assert.Equal(t, []string{"scheduler", "queue", "cache", "database"}, closed)
+}
+
+func TestRunListenError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	closed := []string{}
+	manager := utils.NewAppStateManager()
+	manager.AddClosableDependency("database", closable{"database", &closed})
+
+	gin.SetMode(gin.TestMode)
+	err = manager.Run(context.Background(), gin.New(), listener.Addr().String())
+	assert.Error(t, err)
+	assert.False(t, manager.Started())
+	assert.Equal(t, []string{"database"}, closed)
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const traceparentVersion = "00"
+
+// Traceparent is a W3C trace context header:
+// `00-<32 hex trace id>-<16 hex parent id>-<2 hex flags>`.
+type Traceparent struct {
+	TraceId  string
+	ParentId string
+	Flags    string
+}
+
+// ParseTraceparent parses a traceparent header, rejecting malformed ones and
+// the all zero ids the spec forbids.
+func ParseTraceparent(header string) (Traceparent, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return Traceparent{}, false
+	}
+	// Future versions may append fields, version 00 can't
+	if parts[0] == traceparentVersion && len(parts) != 4 {
+		return Traceparent{}, false
+	}
+
+	traceparent := Traceparent{TraceId: parts[1], ParentId: parts[2], Flags: parts[3]}
+	if !isLowerHex(parts[0], 2) ||
+		!isLowerHex(traceparent.TraceId, 32) || traceparent.TraceId == strings.Repeat("0", 32) ||
+		!isLowerHex(traceparent.ParentId, 16) || traceparent.ParentId == strings.Repeat("0", 16) ||
+		!isLowerHex(traceparent.Flags, 2) {
+		return Traceparent{}, false
+	}
+	return traceparent, true
+}
+
+// NewTraceparent starts a new sampled trace.
+func NewTraceparent() Traceparent {
+	return Traceparent{TraceId: randomHex(16), ParentId: randomHex(8), Flags: "01"}
+}
+
+// Child returns the traceparent to send downstream: same trace, new parent id.
+func (traceparent Traceparent) Child() Traceparent {
+	return Traceparent{TraceId: traceparent.TraceId, ParentId: randomHex(8), Flags: traceparent.Flags}
+}
+
+func (traceparent Traceparent) String() string {
+	return fmt.Sprintf("%s-%s-%s-%s", traceparentVersion, traceparent.TraceId, traceparent.ParentId, traceparent.Flags)
+}
+
+func isLowerHex(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+	for _, character := range value {
+		if !(character >= '0' && character <= '9') && !(character >= 'a' && character <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(size int) string {
+	bytes := make([]byte, size)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -6,9 +6,9 @@ import (
 	lo "github.com/samber/lo"
 )
 
-func IsValidQuery(ctx *gin.Context, s interface{}) (bool, []string) {
+func IsValidQuery(ctx *gin.Context, s interface{}, extraAllowedParams ...string) (bool, []string) {
 	queryParams := ctx.Request.URL.Query()
-	allowedParams := utils.GetStructKeys(s)
+	allowedParams := append(utils.GetStructKeys(s), extraAllowedParams...)
 
 	queryKeys := lo.Keys[string, []string](queryParams)
 	unknownKeys, _ := lo.Difference(queryKeys, allowedParams)
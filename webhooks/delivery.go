@@ -0,0 +1,190 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/webhooks"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// maxResponseBodySize is how much of the response of an endpoint is kept in the delivery log.
+const maxResponseBodySize = 1024
+
+type deliveryJob struct {
+	DeliveryId uuid.UUID `json:"deliveryId"`
+}
+
+type deliveryBody struct {
+	Id        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// deliver sends a delivery once. Returning an error makes the queue retry it
+// with backoff.
+func (dispatcher *Dispatcher) deliver(ctx context.Context, job deliveryJob) error {
+	delivery := webhooks.Delivery{Id: job.DeliveryId}
+	if err := dispatcher.client.DB.NewSelect().Model(&delivery).WherePK().Scan(ctx); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if delivery.Status != webhooks.DeliveryStatusPending {
+		return nil
+	}
+
+	subscription := webhooks.Subscription{Id: delivery.SubscriptionId}
+	err := dispatcher.client.DB.NewSelect().Model(&subscription).WherePK().Where("deleted_at IS NULL").Scan(ctx)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == sql.ErrNoRows || !subscription.Active {
+		return dispatcher.cancel(ctx, delivery)
+	}
+
+	body, err := json.Marshal(deliveryBody{
+		Id:        delivery.EventId,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	startedAt := time.Now()
+	responseStatus, responseBody, sendErr := dispatcher.send(ctx, subscription, delivery, body, startedAt)
+	delivery.Attempts++
+	delivery.DurationMs = time.Since(startedAt).Milliseconds()
+	delivery.ResponseBody = responseBody
+	delivery.UpdatedAt = time.Now()
+	if responseStatus > 0 {
+		delivery.ResponseStatus = &responseStatus
+	}
+
+	return dispatcher.client.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if sendErr == nil {
+			delivery.Status = webhooks.DeliveryStatusDelivered
+			delivery.DeliveredAt = &delivery.UpdatedAt
+			delivery.LastError = nil
+			if _, err := tx.NewUpdate().Model(&delivery).WherePK().Exec(ctx); err != nil {
+				return err
+			}
+			_, err := tx.NewUpdate().
+				Model(&subscription).
+				Set("failures = 0").
+				WherePK().
+				Where("failures > 0").
+				Exec(ctx)
+			return err
+		}
+
+		lastError := sendErr.Error()
+		delivery.LastError = &lastError
+		disabled, err := dispatcher.recordFailure(ctx, tx, subscription)
+		if err != nil {
+			return err
+		}
+		retrying := !disabled && delivery.Attempts < dispatcher.options.MaxAttempts
+		if !retrying {
+			delivery.Status = webhooks.DeliveryStatusFailed
+		}
+		if _, err := tx.NewUpdate().Model(&delivery).WherePK().Exec(ctx); err != nil {
+			return err
+		}
+
+		logger.Component(logComponent).Warn().
+			Err(sendErr).
+			Str("deliveryId", delivery.Id.String()).
+			Str("subscriptionId", subscription.Id.String()).
+			Int("attempts", delivery.Attempts).
+			Bool("retrying", retrying).
+			Msg("[WEBHOOKS] - deliver - Delivery failed")
+		if retrying {
+			return sendErr
+		}
+		return nil
+	})
+}
+
+func (dispatcher *Dispatcher) send(ctx context.Context, subscription webhooks.Subscription, delivery webhooks.Delivery, body []byte, timestamp time.Time) (int, *string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderId, delivery.Id.String())
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	request.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, body))
+
+	response, err := dispatcher.httpClient.Do(request)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+
+	rawResponseBody, _ := io.ReadAll(io.LimitReader(response.Body, maxResponseBodySize))
+	responseBody := string(rawResponseBody)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, &responseBody, fmt.Errorf("Webhook endpoint answered with status %d", response.StatusCode)
+	}
+	return response.StatusCode, &responseBody, nil
+}
+
+// recordFailure counts a failed attempt against the subscription and disables
+// it once it reaches DisableAfter consecutive failures.
+func (dispatcher *Dispatcher) recordFailure(ctx context.Context, tx bun.Tx, subscription webhooks.Subscription) (bool, error) {
+	failures := 0
+	err := tx.NewUpdate().
+		Model(&subscription).
+		Set("failures = failures + 1").
+		WherePK().
+		Returning("failures").
+		Scan(ctx, &failures)
+	if err != nil {
+		return false, err
+	}
+	if failures < dispatcher.options.DisableAfter {
+		return false, nil
+	}
+
+	reason := fmt.Sprintf("Disabled after %d consecutive failed deliveries.", failures)
+	_, err = tx.NewUpdate().
+		Model(&subscription).
+		Set("active = FALSE").
+		Set("disabled_at = CURRENT_TIMESTAMP").
+		Set("disabled_reason = ?", reason).
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	logger.Component(logComponent).Warn().
+		Str("subscriptionId", subscription.Id.String()).
+		Str("url", subscription.Url).
+		Int("failures", failures).
+		Msg("[WEBHOOKS] - recordFailure - Subscription disabled")
+	return true, nil
+}
+
+func (dispatcher *Dispatcher) cancel(ctx context.Context, delivery webhooks.Delivery) error {
+	_, err := dispatcher.client.DB.NewUpdate().
+		Model(&delivery).
+		Set("status = ?", webhooks.DeliveryStatusCancelled).
+		Set("updated_at = CURRENT_TIMESTAMP").
+		WherePK().
+		Exec(ctx)
+	return err
+}
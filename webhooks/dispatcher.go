@@ -0,0 +1,190 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ginerator/base/logger"
+	"github.com/ginerator/base/model/events"
+	"github.com/ginerator/base/model/webhooks"
+	"github.com/ginerator/base/queue"
+	postgres "github.com/ginerator/base/repositories"
+)
+
+const (
+	DeliveryJobKind = "webhooks.deliver"
+	DefaultQueue    = "webhooks"
+
+	logComponent = "webhooks"
+)
+
+type Options struct {
+	// Queue is the job queue deliveries are worked on
+	Queue       string
+	Concurrency int
+	// MaxAttempts is how many times a delivery is tried before it's failed
+	MaxAttempts int
+	Timeout     time.Duration
+	// DisableAfter is how many consecutive failed attempts disable a subscription
+	DisableAfter int
+	// AllowPrivateNetworks lets urls use http and reach loopback and private
+	// addresses, for local development only
+	AllowPrivateNetworks bool
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Queue:        DefaultQueue,
+		Concurrency:  4,
+		MaxAttempts:  8,
+		Timeout:      10 * time.Second,
+		DisableAfter: 50,
+	}
+}
+
+// Dispatcher fans events out to the webhook subscriptions of their owner and
+// delivers them through the job queue, which retries them with backoff.
+type Dispatcher struct {
+	client        *postgres.BunPostgresDatabaseClient
+	queue         *queue.Queue
+	options       Options
+	httpClient    *http.Client
+	subscriptions *postgres.PostgresRepository[webhooks.Subscription]
+}
+
+func NewDispatcher(client *postgres.BunPostgresDatabaseClient, jobQueue *queue.Queue, options Options) *Dispatcher {
+	defaults := DefaultOptions()
+	if options.Queue == "" {
+		options.Queue = defaults.Queue
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = defaults.Concurrency
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = defaults.MaxAttempts
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaults.Timeout
+	}
+	if options.DisableAfter <= 0 {
+		options.DisableAfter = defaults.DisableAfter
+	}
+
+	dispatcher := &Dispatcher{
+		client:        client,
+		queue:         jobQueue,
+		options:       options,
+		httpClient:    newHttpClient(options),
+		subscriptions: postgres.NewPostgresRepository[webhooks.Subscription](client),
+	}
+	client.RegisterModels(new(webhooks.Delivery))
+	queue.Register(jobQueue, DeliveryJobKind, dispatcher.deliver)
+	jobQueue.SetConcurrency(options.Queue, options.Concurrency)
+
+	logger.Component(logComponent).Info().Msg("Webhook dispatcher initialized.")
+	return dispatcher
+}
+
+// Dispatch creates a delivery of the event for every active subscription of
+// the user to its type. Called with the context of a transaction, nothing is
+// delivered unless the transaction commits. Dispatching the same event id
+// twice to a subscription is a no-op.
+func (dispatcher *Dispatcher) Dispatch(ctx context.Context, userId string, eventId string, eventType string, payload interface{}) error {
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	db := dispatcher.client.GetDB(ctx)
+	subscriptions := make([]webhooks.Subscription, 0)
+	err = db.NewSelect().
+		Model(&subscriptions).
+		Where("userid = ?", userId).
+		Where("active").
+		Where("deleted_at IS NULL").
+		Where("(? = ANY(event_types) OR ? = ANY(event_types))", eventType, webhooks.EventTypeAll).
+		Scan(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("eventType", eventType).Msg("[WEBHOOKS] - Dispatch - Error finding subscriptions")
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		delivery := webhooks.Delivery{
+			SubscriptionId: subscription.Id,
+			EventId:        eventId,
+			EventType:      eventType,
+			Payload:        rawPayload,
+			Status:         webhooks.DeliveryStatusPending,
+		}
+		result, err := db.NewInsert().
+			Model(&delivery).
+			On("CONFLICT (subscription_id, event_id) DO NOTHING").
+			Returning("id").
+			Exec(ctx)
+		if err != nil {
+			logger.For(ctx, logComponent).Error().Err(err).Str("subscriptionId", subscription.Id.String()).Msg("[WEBHOOKS] - Dispatch - Error creating delivery")
+			return err
+		}
+		if inserted, _ := result.RowsAffected(); inserted == 0 {
+			continue
+		}
+
+		_, err = dispatcher.queue.Enqueue(ctx, DeliveryJobKind, deliveryJob{DeliveryId: delivery.Id},
+			queue.WithQueue(dispatcher.options.Queue),
+			queue.WithMaxAttempts(dispatcher.options.MaxAttempts),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Publish lets the dispatcher be the publisher of an outbox relay: events
+// with a `userId` header are dispatched to the subscriptions of that user.
+func (dispatcher *Dispatcher) Publish(ctx context.Context, message events.OutboxMessage) error {
+	userId, exists := message.Headers[events.HeaderUserId]
+	if !exists {
+		return nil
+	}
+	return dispatcher.Dispatch(ctx, userId, strconv.FormatInt(message.Id, 10), message.EventType, message.Payload)
+}
+
+// CreateTables creates the subscriptions and deliveries tables if they don't exist yet.
+func (dispatcher *Dispatcher) CreateTables(ctx context.Context) error {
+	db := dispatcher.client.DB
+	for _, model := range []interface{}{(*webhooks.Subscription)(nil), (*webhooks.Delivery)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+			logger.Component(logComponent).Error().Err(err).Msg("[WEBHOOKS] - CreateTables - Error creating table")
+			return err
+		}
+	}
+
+	_, err := db.NewCreateIndex().
+		Model((*webhooks.Delivery)(nil)).
+		Index("webhook_deliveries_event_idx").
+		Unique().
+		IfNotExists().
+		Column("subscription_id", "event_id").
+		Exec(ctx)
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[WEBHOOKS] - CreateTables - Error creating deliveries index")
+		return err
+	}
+
+	_, err = db.NewCreateIndex().
+		Model((*webhooks.Subscription)(nil)).
+		Index("webhook_subscriptions_userid_idx").
+		IfNotExists().
+		Column("userid").
+		Where("deleted_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		logger.Component(logComponent).Error().Err(err).Msg("[WEBHOOKS] - CreateTables - Error creating subscriptions index")
+	}
+	return err
+}
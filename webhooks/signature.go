@@ -0,0 +1,61 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderId        = "Webhook-Id"
+	HeaderTimestamp = "Webhook-Timestamp"
+	HeaderSignature = "Webhook-Signature"
+
+	signatureVersion = "v1"
+	secretPrefix     = "whsec_"
+)
+
+// Sign returns the signature header of a delivery: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery the way receivers should: the signature has to
+// match and the timestamp can't be older than tolerance, to prevent replays.
+func Verify(secret string, rawTimestamp string, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	unixTimestamp, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid webhook timestamp '%s'", rawTimestamp)
+	}
+	timestamp := time.Unix(unixTimestamp, 0)
+	if now.Sub(timestamp) > tolerance || timestamp.Sub(now) > tolerance {
+		return fmt.Errorf("Webhook timestamp %s is outside the tolerance", timestamp.Format(time.RFC3339))
+	}
+
+	expected := Sign(secret, timestamp, body)
+	// Several signatures are sent while secrets rotate
+	for _, candidate := range strings.Split(signature, " ") {
+		if hmac.Equal([]byte(candidate), []byte(expected)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid webhook signature")
+}
+
+func newSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return secretPrefix + hex.EncodeToString(secret), nil
+}
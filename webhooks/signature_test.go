@@ -0,0 +1,42 @@
+//go:build unit
+
+package webhooks_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginerator/base/webhooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"type":"rooms.created"}`)
+	now := time.Unix(1700000000, 0)
+	signature := webhooks.Sign(secret, now, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		now       time.Time
+		valid     bool
+	}{
+		{"valid", secret, "1700000000", signature, body, now, true},
+		{"rotated secrets", secret, "1700000000", "v1=deadbeef " + signature, body, now, true},
+		{"wrong secret", "whsec_other", "1700000000", signature, body, now, false},
+		{"tampered body", secret, "1700000000", signature, []byte(`{"type":"rooms.deleted"}`), now, false},
+		{"replayed", secret, "1700000000", signature, body, now.Add(10 * time.Minute), false},
+		{"invalid timestamp", secret, "yesterday", signature, body, now, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := webhooks.Verify(test.secret, test.timestamp, test.signature, test.body, 5*time.Minute, test.now)
+			assert.Equal(t, test.valid, err == nil, err)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ginerator/base/errors"
+	"github.com/ginerator/base/logger"
+	modelquery "github.com/ginerator/base/model/query"
+	user "github.com/ginerator/base/model/users"
+	"github.com/ginerator/base/model/webhooks"
+	"github.com/ginerator/base/utils"
+	"github.com/google/uuid"
+)
+
+// The functions below are the service functions of the subscription
+// endpoints. Users only see their own subscriptions, admins every one.
+
+// subscriptionOwner returns the actor subscriptions are scoped to, nil for
+// admins. The userId set by CheckAuthorization only tells the caller has the
+// Own permission, not who they are, so the authenticated user is used.
+func subscriptionOwner(ctx *gin.Context, forAdmin bool) (*string, error) {
+	if forAdmin && utils.GetUserId(ctx) == nil {
+		return nil, nil
+	}
+	if contextUser := user.GetUser(ctx); contextUser != nil {
+		if actor := contextUser.Actor(); actor != nil {
+			return actor, nil
+		}
+	}
+	return nil, errors.NewForbiddenError(fmt.Errorf("Webhook subscriptions belong to a user."))
+}
+
+func (dispatcher *Dispatcher) CreateSubscription(ctx *gin.Context, request webhooks.CreateSubscriptionRequest) (webhooks.CreatedSubscription, error) {
+	userId, err := subscriptionOwner(ctx, false)
+	if err != nil {
+		return webhooks.CreatedSubscription{}, err
+	}
+	if err := dispatcher.checkUrl(ctx, request.Url); err != nil {
+		return webhooks.CreatedSubscription{}, errors.NewInvalidPayloadError("INVALID_WEBHOOK_URL", err)
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return webhooks.CreatedSubscription{}, errors.NewInternalServerError("UNKNOWN_ERROR", err)
+	}
+
+	subscription, err := dispatcher.subscriptions.Create(ctx, &webhooks.Subscription{
+		UserId:     *userId,
+		Url:        request.Url,
+		EventTypes: request.EventTypes,
+		Secret:     secret,
+		Active:     true,
+	})
+	if err != nil {
+		return webhooks.CreatedSubscription{}, err
+	}
+	return webhooks.CreatedSubscription{Subscription: subscription, Secret: secret}, nil
+}
+
+func (dispatcher *Dispatcher) GetSubscription(ctx *gin.Context, id uuid.UUID) (webhooks.Subscription, error) {
+	userId, err := subscriptionOwner(ctx, true)
+	if err != nil {
+		return webhooks.Subscription{}, err
+	}
+	return dispatcher.subscriptions.GetOne(ctx, id, userId)
+}
+
+func (dispatcher *Dispatcher) GetSubscriptions(ctx *gin.Context, query webhooks.SubscriptionQuery) ([]webhooks.Subscription, modelquery.ResponseMeta, error) {
+	userId, err := subscriptionOwner(ctx, true)
+	if err != nil {
+		return []webhooks.Subscription{}, modelquery.ResponseMeta{}, err
+	}
+	return dispatcher.subscriptions.GetMany(ctx, query, userId)
+}
+
+// UpdateSubscription also re-enables subscriptions: setting `active` resets
+// their failures.
+func (dispatcher *Dispatcher) UpdateSubscription(ctx *gin.Context, id uuid.UUID, request webhooks.UpdateSubscriptionRequest) (webhooks.Subscription, error) {
+	userId, err := subscriptionOwner(ctx, true)
+	if err != nil {
+		return webhooks.Subscription{}, err
+	}
+	if request.Url != nil {
+		if err := dispatcher.checkUrl(ctx, *request.Url); err != nil {
+			return webhooks.Subscription{}, errors.NewInvalidPayloadError("INVALID_WEBHOOK_URL", err)
+		}
+	}
+
+	subscription, err := dispatcher.subscriptions.UpdateOne(ctx, id, &request, userId)
+	if err != nil || request.Active == nil || !*request.Active {
+		return subscription, err
+	}
+
+	_, err = dispatcher.client.GetDB(ctx).NewUpdate().
+		Model(&subscription).
+		Set("failures = 0").
+		Set("disabled_at = NULL").
+		Set("disabled_reason = NULL").
+		WherePK().
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("id", id.String()).Msg("[WEBHOOKS] - UpdateSubscription - Error re-enabling subscription")
+		return subscription, errors.NewUnkownDatabaseError(err)
+	}
+	return subscription, nil
+}
+
+func (dispatcher *Dispatcher) DeleteSubscription(ctx *gin.Context, id uuid.UUID) (webhooks.Subscription, error) {
+	userId, err := subscriptionOwner(ctx, true)
+	if err != nil {
+		return webhooks.Subscription{}, err
+	}
+	return dispatcher.subscriptions.DeleteOne(ctx, id, userId)
+}
+
+// GetDeliveries returns the delivery log of a subscription, newest first.
+func (dispatcher *Dispatcher) GetDeliveries(ctx *gin.Context, subscriptionId uuid.UUID, query webhooks.DeliveryQuery) ([]webhooks.Delivery, modelquery.ResponseMeta, error) {
+	deliveries := make([]webhooks.Delivery, 0)
+	if _, err := dispatcher.GetSubscription(ctx, subscriptionId); err != nil {
+		return deliveries, modelquery.ResponseMeta{}, err
+	}
+
+	dbQuery := dispatcher.client.GetDB(ctx).NewSelect().
+		Model(&deliveries).
+		Where("subscription_id = ?", subscriptionId)
+	offset, limit := utils.BuildControlQuery(ctx, dbQuery)
+
+	count, err := dbQuery.ScanAndCount(ctx)
+	if err != nil {
+		logger.For(ctx, logComponent).Error().Err(err).Str("subscriptionId", subscriptionId.String()).Msg("[WEBHOOKS] - GetDeliveries - Unhandled error")
+		return deliveries, modelquery.ResponseMeta{}, errors.NewUnkownDatabaseError(err)
+	}
+	return deliveries, utils.BuildResponseMeta(offset, limit, count), nil
+}
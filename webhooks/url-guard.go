@@ -0,0 +1,100 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// reservedNetworks aren't covered by the net.IP checks: "this network", the
+// carrier-grade NAT shared address space, and the NAT64 prefixes which map to
+// IPv4 addresses, internal ones included, on NAT64 networks.
+var reservedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("64:ff9b::/96"),
+	mustParseCIDR("64:ff9b:1::/48"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// isPublicIP rejects the addresses a webhook must not reach: loopback,
+// private, link-local (which includes the 169.254.169.254 metadata
+// endpoint), unspecified, multicast and the reserved networks.
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkUrl accepts https URLs whose host only resolves to public addresses.
+func (dispatcher *Dispatcher) checkUrl(ctx context.Context, rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || parsed.Hostname() == "" {
+		return fmt.Errorf("Invalid webhook url.")
+	}
+	if dispatcher.options.AllowPrivateNetworks {
+		return nil
+	}
+	if parsed.Scheme != "https" {
+		return fmt.Errorf("Webhook urls must use https.")
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("Webhook url host %s can't be resolved.", parsed.Hostname())
+	}
+	for _, address := range addresses {
+		if !isPublicIP(address.IP) {
+			return fmt.Errorf("Webhook url host %s resolves to a non public address.", parsed.Hostname())
+		}
+	}
+	return nil
+}
+
+// newHttpClient checks the address again when connecting, so a host
+// re-pointed after subscribing, or a redirect, can't reach internal addresses.
+func newHttpClient(options Options) *http.Client {
+	dialer := &net.Dialer{Timeout: options.Timeout, KeepAlive: 30 * time.Second}
+	if !options.AllowPrivateNetworks {
+		dialer.Control = func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("Webhook delivery to non public address %s refused.", host)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: options.Timeout,
+		Transport: &http.Transport{
+			// Not taken from the environment, a proxy would dial on our behalf
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: options.Timeout,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,45 @@
+//go:build unit
+
+package webhooks
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		ip       string
+		expected bool
+	}{
+		{"public IPv4", "93.184.216.34", true},
+		{"public IPv6", "2606:2800:220:1:248:1893:25c8:1946", true},
+		{"loopback", "127.0.0.1", false},
+		{"loopback IPv6", "::1", false},
+		{"this network", "0.1.2.3", false},
+		{"unspecified", "0.0.0.0", false},
+		{"private 10/8", "10.1.2.3", false},
+		{"private 172.16/12", "172.20.0.1", false},
+		{"private 192.168/16", "192.168.1.1", false},
+		{"unique local IPv6", "fd00::1", false},
+		{"metadata endpoint", "169.254.169.254", false},
+		{"link-local IPv6", "fe80::1", false},
+		{"shared address space", "100.100.0.1", false},
+		{"multicast", "224.0.0.1", false},
+		{"IPv4-mapped loopback", "::ffff:127.0.0.1", false},
+		{"IPv4-mapped private", "::ffff:10.0.0.1", false},
+		{"IPv4-mapped public", "::ffff:93.184.216.34", true},
+		{"NAT64 private", "64:ff9b::a00:1", false},
+		{"NAT64 metadata", "64:ff9b::a9fe:a9fe", false},
+		{"local-use NAT64", "64:ff9b:1::a00:1", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isPublicIP(net.ParseIP(test.ip)))
+		})
+	}
+}